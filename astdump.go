@@ -0,0 +1,617 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// sexprPrinter prints the AST as S-expressions. Tokens and literals are
+// printed as lexeme@line.
+type sexprPrinter struct {
+	out    strings.Builder
+	indent int
+}
+
+// DumpSExpr returns one S-expression per statement.
+func DumpSExpr(stmts []Stmt) string {
+	p := &sexprPrinter{}
+	for _, stmt := range stmts {
+		p.stmt(stmt)
+		p.out.WriteString("\n")
+	}
+	return p.out.String()
+}
+
+// tokenSExpr prints tokens the parser made up, like the operators of an
+// interpolated string, by their type.
+func tokenSExpr(t Token) string {
+	if t.lexeme == "" {
+		return strings.ToLower(t.tType.String()) + "@" + strconv.Itoa(t.line)
+	}
+	return t.lexeme + "@" + strconv.Itoa(t.line)
+}
+
+// open starts a statement on a new line, one level deeper than its parent.
+func (p *sexprPrinter) open(head string) {
+	if p.indent > 0 {
+		p.out.WriteString("\n" + strings.Repeat("  ", p.indent))
+	}
+	p.out.WriteString("(" + head)
+	p.indent++
+}
+
+func (p *sexprPrinter) close() {
+	p.out.WriteString(")")
+	p.indent--
+}
+
+func (p *sexprPrinter) stmt(stmt Stmt) {
+	if stmt == nil {
+		p.out.WriteString(" nil")
+		return
+	}
+	stmt.Accept(p)
+}
+
+// expr writes an optional expression on the statement's line.
+func (p *sexprPrinter) expr(expr Expr) {
+	if expr == nil {
+		p.out.WriteString(" nil")
+		return
+	}
+	p.out.WriteString(" " + expr.Accept(p).(string))
+}
+
+func (p *sexprPrinter) VisitBlock(b *Block) {
+	p.open("block")
+	for _, stmt := range b.statements {
+		p.stmt(stmt)
+	}
+	p.close()
+}
+
+func (p *sexprPrinter) VisitExpressionStmt(e *Expression) {
+	p.open("expression")
+	p.expr(e.expr)
+	p.close()
+}
+
+func (p *sexprPrinter) VisitFunction(f *Function) {
+	var params []string
+	for _, param := range f.params {
+		params = append(params, tokenSExpr(param))
+	}
+	p.open("fun " + tokenSExpr(f.name) + " (" + strings.Join(params, " ") + ")")
+	for _, stmt := range f.body {
+		p.stmt(stmt)
+	}
+	p.close()
+}
+
+func (p *sexprPrinter) VisitFor(f *For) {
+	p.open("for@" + strconv.Itoa(f.keyword.line))
+	p.stmt(f.initializer)
+	p.expr(f.condition)
+	p.expr(f.increment)
+	p.stmt(f.body)
+	p.close()
+}
+
+func (p *sexprPrinter) VisitWhile(w *While) {
+	p.open("while@" + strconv.Itoa(w.keyword.line))
+	p.expr(w.condition)
+	p.stmt(w.body)
+	p.close()
+}
+
+func (p *sexprPrinter) VisitIf(i *If) {
+	p.open("if@" + strconv.Itoa(i.keyword.line))
+	p.expr(i.condition)
+	p.stmt(i.thenBranch)
+	if i.elseBranch != nil {
+		p.stmt(i.elseBranch)
+	}
+	p.close()
+}
+
+func (p *sexprPrinter) VisitPrint(pr *Print) {
+	p.open("print@" + strconv.Itoa(pr.keyword.line))
+	p.expr(pr.expr)
+	p.close()
+}
+
+func (p *sexprPrinter) VisitReturn(r *Return) {
+	p.open("return@" + strconv.Itoa(r.keyword.line))
+	if r.value != nil {
+		p.expr(r.value)
+	}
+	p.close()
+}
+
+func (p *sexprPrinter) VisitVarStmt(v *Var) {
+	keyword := "var "
+	if v.constant {
+		keyword = "const "
+	}
+	p.open(keyword + tokenSExpr(v.name))
+	if v.initializer != nil {
+		p.expr(v.initializer)
+	}
+	p.close()
+}
+
+func (p *sexprPrinter) parenthesize(head string, exprs ...Expr) string {
+	parts := []string{head}
+	for _, expr := range exprs {
+		parts = append(parts, expr.Accept(p).(string))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func (p *sexprPrinter) VisitAssign(a *Assign) any {
+	switch {
+	case a.operator.tType == EQUAL:
+		return p.parenthesize("assign "+tokenSExpr(a.name), a.value)
+	case a.postfix:
+		return "(postfix " + tokenSExpr(a.operator) + " " + tokenSExpr(a.name) + ")"
+	case a.operator.tType == PLUS_PLUS || a.operator.tType == MINUS_MINUS:
+		return "(" + tokenSExpr(a.operator) + " " + tokenSExpr(a.name) + ")"
+	}
+	return p.parenthesize(tokenSExpr(a.operator)+" "+tokenSExpr(a.name), a.value)
+}
+
+func (p *sexprPrinter) VisitBinary(b *Binary) any {
+	return p.parenthesize(tokenSExpr(b.Operator), b.Left, b.Right)
+}
+
+func (p *sexprPrinter) VisitCallExpr(c *Call) any {
+	return p.parenthesize("call@"+strconv.Itoa(c.paren.line), append([]Expr{c.callee}, c.arguments...)...)
+}
+
+func (p *sexprPrinter) VisitGrouping(g *Grouping) any {
+	return p.parenthesize("group", g.Expression)
+}
+
+func (p *sexprPrinter) VisitLiteral(l *Literal) any {
+	line := "@" + strconv.Itoa(l.line)
+	switch value := l.Value.(type) {
+	case nil:
+		return "nil" + line
+	case string:
+		return strconv.Quote(value) + line
+	case int64, *big.Int, *Decimal, float64:
+		return numberLiteral(value) + line
+	}
+	return fmt.Sprintf("%v", l.Value) + line
+}
+
+func (p *sexprPrinter) VisitConditional(c *Conditional) any {
+	return p.parenthesize(tokenSExpr(c.question), c.condition, c.thenBranch, c.elseBranch)
+}
+
+func (p *sexprPrinter) VisitLogical(l *Logical) any {
+	return p.parenthesize(tokenSExpr(l.operator), l.left, l.right)
+}
+
+func (p *sexprPrinter) VisitVariableExpr(v *Variable) any {
+	return tokenSExpr(v.name)
+}
+
+func (p *sexprPrinter) VisitUnary(u *Unary) any {
+	return p.parenthesize(tokenSExpr(u.Operator), u.Right)
+}
+
+// jsonEncoder turns the AST into maps that encoding/json can marshal.
+// Every node has a "type" naming its Go type; LoadAST reverses it.
+type jsonEncoder struct {
+	result map[string]any
+}
+
+// DumpJSON returns the program as an indented JSON array of statements.
+func DumpJSON(stmts []Stmt) string {
+	e := &jsonEncoder{}
+	data, err := json.MarshalIndent(e.stmts(stmts), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(data) + "\n"
+}
+
+func tokenJSON(t Token) map[string]any {
+	return map[string]any{
+		"type":   t.tType.String(),
+		"lexeme": t.lexeme,
+		"line":   t.line,
+		"column": t.column,
+	}
+}
+
+func (e *jsonEncoder) stmt(stmt Stmt) any {
+	if stmt == nil {
+		return nil
+	}
+	stmt.Accept(e)
+	return e.result
+}
+
+func (e *jsonEncoder) stmts(stmts []Stmt) []any {
+	result := []any{}
+	for _, stmt := range stmts {
+		result = append(result, e.stmt(stmt))
+	}
+	return result
+}
+
+func (e *jsonEncoder) expr(expr Expr) any {
+	if expr == nil {
+		return nil
+	}
+	return expr.Accept(e)
+}
+
+func (e *jsonEncoder) VisitBlock(b *Block) {
+	e.result = map[string]any{"type": "Block", "statements": e.stmts(b.statements)}
+}
+
+func (e *jsonEncoder) VisitExpressionStmt(s *Expression) {
+	e.result = map[string]any{"type": "Expression", "expression": e.expr(s.expr)}
+}
+
+func (e *jsonEncoder) VisitFunction(f *Function) {
+	params := []any{}
+	for _, param := range f.params {
+		params = append(params, tokenJSON(param))
+	}
+	e.result = map[string]any{
+		"type":   "Function",
+		"name":   tokenJSON(f.name),
+		"params": params,
+		"body":   e.stmts(f.body),
+	}
+	if f.doc != "" {
+		e.result["doc"] = f.doc
+	}
+}
+
+func (e *jsonEncoder) VisitFor(f *For) {
+	e.result = map[string]any{
+		"type":        "For",
+		"keyword":     tokenJSON(f.keyword),
+		"initializer": e.stmt(f.initializer),
+		"condition":   e.expr(f.condition),
+		"increment":   e.expr(f.increment),
+		"body":        e.stmt(f.body),
+	}
+}
+
+func (e *jsonEncoder) VisitWhile(w *While) {
+	e.result = map[string]any{
+		"type":      "While",
+		"keyword":   tokenJSON(w.keyword),
+		"condition": e.expr(w.condition),
+		"body":      e.stmt(w.body),
+	}
+}
+
+func (e *jsonEncoder) VisitIf(i *If) {
+	e.result = map[string]any{
+		"type":       "If",
+		"keyword":    tokenJSON(i.keyword),
+		"condition":  e.expr(i.condition),
+		"thenBranch": e.stmt(i.thenBranch),
+		"elseBranch": e.stmt(i.elseBranch),
+	}
+}
+
+func (e *jsonEncoder) VisitPrint(p *Print) {
+	e.result = map[string]any{
+		"type":       "Print",
+		"keyword":    tokenJSON(p.keyword),
+		"expression": e.expr(p.expr),
+	}
+}
+
+func (e *jsonEncoder) VisitReturn(r *Return) {
+	e.result = map[string]any{
+		"type":    "Return",
+		"keyword": tokenJSON(r.keyword),
+		"value":   e.expr(r.value),
+	}
+}
+
+func (e *jsonEncoder) VisitVarStmt(v *Var) {
+	e.result = map[string]any{
+		"type":        "Var",
+		"name":        tokenJSON(v.name),
+		"initializer": e.expr(v.initializer),
+	}
+	if v.constant {
+		e.result["constant"] = true
+	}
+}
+
+func (e *jsonEncoder) VisitAssign(a *Assign) any {
+	return map[string]any{
+		"type":     "Assign",
+		"name":     tokenJSON(a.name),
+		"operator": tokenJSON(a.operator),
+		"value":    e.expr(a.value),
+		"postfix":  a.postfix,
+	}
+}
+
+func (e *jsonEncoder) VisitBinary(b *Binary) any {
+	return map[string]any{
+		"type":     "Binary",
+		"left":     e.expr(b.Left),
+		"operator": tokenJSON(b.Operator),
+		"right":    e.expr(b.Right),
+	}
+}
+
+func (e *jsonEncoder) VisitCallExpr(c *Call) any {
+	arguments := []any{}
+	for _, argument := range c.arguments {
+		arguments = append(arguments, e.expr(argument))
+	}
+	return map[string]any{
+		"type":      "Call",
+		"callee":    e.expr(c.callee),
+		"paren":     tokenJSON(c.paren),
+		"arguments": arguments,
+	}
+}
+
+func (e *jsonEncoder) VisitGrouping(g *Grouping) any {
+	return map[string]any{"type": "Grouping", "expression": e.expr(g.Expression)}
+}
+
+func (e *jsonEncoder) VisitLiteral(l *Literal) any {
+	switch value := l.Value.(type) {
+	case float64:
+		// Keep the decimal point, so the value is loaded as a float.
+		return map[string]any{"type": "Literal", "value": json.Number(floatLiteral(value)), "line": l.line}
+	case *big.Int, *Decimal:
+		// JSON numbers can't say which kind they are, so these are
+		// written as strings in their source form, e.g. "1.10d".
+		return map[string]any{"type": "Literal", "value": numberLiteral(value), "number": true, "line": l.line}
+	}
+	return map[string]any{"type": "Literal", "value": l.Value, "line": l.line}
+}
+
+func (e *jsonEncoder) VisitConditional(c *Conditional) any {
+	return map[string]any{
+		"type":       "Conditional",
+		"condition":  e.expr(c.condition),
+		"question":   tokenJSON(c.question),
+		"thenBranch": e.expr(c.thenBranch),
+		"elseBranch": e.expr(c.elseBranch),
+	}
+}
+
+func (e *jsonEncoder) VisitLogical(l *Logical) any {
+	return map[string]any{
+		"type":     "Logical",
+		"left":     e.expr(l.left),
+		"operator": tokenJSON(l.operator),
+		"right":    e.expr(l.right),
+	}
+}
+
+func (e *jsonEncoder) VisitVariableExpr(v *Variable) any {
+	return map[string]any{"type": "Variable", "name": tokenJSON(v.name)}
+}
+
+func (e *jsonEncoder) VisitUnary(u *Unary) any {
+	return map[string]any{"type": "Unary", "operator": tokenJSON(u.Operator), "right": e.expr(u.Right)}
+}
+
+// ASTError reports a JSON document that doesn't describe a Lox program.
+type ASTError struct {
+	msg string
+}
+
+func (e ASTError) Error() string {
+	return e.msg
+}
+
+var tokenTypes map[string]TokenType
+
+func init() {
+	tokenTypes = map[string]TokenType{}
+	for t := LEFT_PAREN; t <= EOF; t++ {
+		tokenTypes[t.String()] = t
+	}
+}
+
+// LoadAST reads a program in the format written by DumpJSON. Token
+// positions and keywords are optional, so tools can generate programs
+// easily.
+func LoadAST(data []byte) (stmts []Stmt, err error) {
+	var doc []any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			astError, ok := e.(ASTError)
+			if !ok {
+				panic(e)
+			}
+			stmts, err = nil, astError
+		}
+	}()
+	return loadStmts(doc), nil
+}
+
+func loadNode(value any) map[string]any {
+	node, ok := value.(map[string]any)
+	if !ok {
+		panic(ASTError{fmt.Sprintf("Expected an object but got %v.", value)})
+	}
+	return node
+}
+
+func loadList(value any) []any {
+	list, ok := value.([]any)
+	if !ok && value != nil {
+		panic(ASTError{fmt.Sprintf("Expected an array but got %v.", value)})
+	}
+	return list
+}
+
+func loadToken(value any) Token {
+	node := loadNode(value)
+	lexeme, _ := node["lexeme"].(string)
+	line, _ := node["line"].(json.Number)
+	column, _ := node["column"].(json.Number)
+	tType, ok := tokenTypes[fmt.Sprint(node["type"])]
+	if !ok {
+		panic(ASTError{fmt.Sprintf("Unknown token type %v.", node["type"])})
+	}
+	return Token{tType, lexeme, nil, loadInt(line), loadInt(column), nil, ""}
+}
+
+// loadKeyword is like loadToken, but keywords may be left out.
+func loadKeyword(value any, tType TokenType, lexeme string) Token {
+	if value == nil {
+		return Token{tType, lexeme, nil, 0, 0, nil, ""}
+	}
+	return loadToken(value)
+}
+
+// loadInt returns 0 for missing positions.
+func loadInt(number json.Number) int {
+	value, _ := number.Int64()
+	return int(value)
+}
+
+// loadLiteral loads numbers with a fraction or exponent as floats and
+// all others as integers.
+func loadLiteral(value any) any {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if !strings.ContainsAny(string(number), ".eE") {
+		if integer, err := number.Int64(); err == nil {
+			return integer
+		}
+	}
+	float, err := number.Float64()
+	if err != nil {
+		panic(ASTError{fmt.Sprintf("Invalid number %v.", number)})
+	}
+	return float
+}
+
+// loadNumber loads a big integer or decimal written like in source.
+func loadNumber(value any) any {
+	text, _ := value.(string)
+	tokens := NewScanner(text).scanTokens()
+	if len(tokens) != 2 || tokens[0].tType != NUMBER || hadError {
+		hadError = false
+		panic(ASTError{fmt.Sprintf("Invalid number %v.", value)})
+	}
+	return tokens[0].literal
+}
+
+func loadStmts(value any) []Stmt {
+	var stmts []Stmt
+	for _, stmt := range loadList(value) {
+		stmts = append(stmts, loadStmt(stmt))
+	}
+	return stmts
+}
+
+func loadOptionalStmt(value any) Stmt {
+	if value == nil {
+		return nil
+	}
+	return loadStmt(value)
+}
+
+func loadStmt(value any) Stmt {
+	node := loadNode(value)
+	switch node["type"] {
+	case "Block":
+		return &Block{loadStmts(node["statements"])}
+	case "Expression":
+		return &Expression{loadExpr(node["expression"])}
+	case "Function":
+		var params []Token
+		for _, param := range loadList(node["params"]) {
+			params = append(params, loadToken(param))
+		}
+		doc, _ := node["doc"].(string)
+		return &Function{loadToken(node["name"]), params, loadStmts(node["body"]), doc}
+	case "For":
+		return NewFor(loadKeyword(node["keyword"], FOR, "for"),
+			loadOptionalStmt(node["initializer"]), loadOptionalExpr(node["condition"]),
+			loadOptionalExpr(node["increment"]), loadStmt(node["body"]))
+	case "While":
+		return &While{loadKeyword(node["keyword"], WHILE, "while"),
+			loadExpr(node["condition"]), loadStmt(node["body"])}
+	case "If":
+		return &If{loadKeyword(node["keyword"], IF, "if"), loadExpr(node["condition"]),
+			loadStmt(node["thenBranch"]), loadOptionalStmt(node["elseBranch"])}
+	case "Print":
+		return &Print{loadKeyword(node["keyword"], PRINT, "print"), loadExpr(node["expression"])}
+	case "Return":
+		return &Return{loadKeyword(node["keyword"], RETURN, "return"), loadOptionalExpr(node["value"])}
+	case "Var":
+		constant, _ := node["constant"].(bool)
+		return &Var{loadToken(node["name"]), loadOptionalExpr(node["initializer"]), constant}
+	}
+	panic(ASTError{fmt.Sprintf("Unknown statement type %v.", node["type"])})
+}
+
+func loadOptionalExpr(value any) Expr {
+	if value == nil {
+		return nil
+	}
+	return loadExpr(value)
+}
+
+func loadExpr(value any) Expr {
+	node := loadNode(value)
+	switch node["type"] {
+	case "Assign":
+		postfix, _ := node["postfix"].(bool)
+		return &Assign{loadToken(node["name"]), loadKeyword(node["operator"], EQUAL, "="),
+			loadExpr(node["value"]), postfix}
+	case "Binary":
+		return &Binary{loadExpr(node["left"]), loadToken(node["operator"]), loadExpr(node["right"])}
+	case "Call":
+		var arguments []Expr
+		for _, argument := range loadList(node["arguments"]) {
+			arguments = append(arguments, loadExpr(argument))
+		}
+		paren := loadKeyword(node["paren"], RIGHT_PAREN, ")")
+		return &Call{loadExpr(node["callee"]), paren, arguments}
+	case "Grouping":
+		return &Grouping{loadExpr(node["expression"])}
+	case "Literal":
+		line, _ := node["line"].(json.Number)
+		if number, _ := node["number"].(bool); number {
+			return &Literal{loadNumber(node["value"]), loadInt(line)}
+		}
+		return &Literal{loadLiteral(node["value"]), loadInt(line)}
+	case "Conditional":
+		return &Conditional{loadExpr(node["condition"]), loadKeyword(node["question"], QUESTION, "?"),
+			loadExpr(node["thenBranch"]), loadExpr(node["elseBranch"])}
+	case "Logical":
+		return &Logical{loadExpr(node["left"]), loadToken(node["operator"]), loadExpr(node["right"])}
+	case "Variable":
+		return &Variable{loadToken(node["name"])}
+	case "Unary":
+		return &Unary{loadToken(node["operator"]), loadExpr(node["right"])}
+	}
+	panic(ASTError{fmt.Sprintf("Unknown expression type %v.", node["type"])})
+}
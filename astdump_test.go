@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDumpSExpr(t *testing.T) {
+	source := "var a = 1;\nfun add(x, y) {\n  return x + y;\n}\nif (a > 0) print add(a, 2); else { a = -a; }\nfor (;;) {}\n"
+	want := `(var a@1 1@1)
+(fun add@2 (x@2 y@2)
+  (return@3 (+@3 x@3 y@3)))
+(if@5 (>@5 a@5 0@5)
+  (print@5 (call@5 add@5 a@5 2@5))
+  (block
+    (expression (assign a@5 (-@5 a@5)))))
+(for@6 nil nil nil
+  (block))
+`
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	if got := DumpSExpr(stmts); got != want {
+		t.Errorf("DumpSExpr() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestDumpJSONLiteralLine(t *testing.T) {
+	stmts := NewParser(NewScanner("print\n  1.5;\n").scanTokens()).parse()
+	var dumped []struct {
+		Expression struct {
+			Type string
+			Line int
+		}
+	}
+	if err := json.Unmarshal([]byte(DumpJSON(stmts)), &dumped); err != nil {
+		t.Fatal(err)
+	}
+	if got := dumped[0].Expression; got.Type != "Literal" || got.Line != 2 {
+		t.Errorf("dumped %+v, want a Literal on line 2", got)
+	}
+}
+
+func TestLoadAST(t *testing.T) {
+	source := `fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 2) + fib(n - 1);
+}
+for (var i = 0; i < 5; i = i + 1) {
+  print "fib " + "(" + (nil == nil and !false or true) + ")";
+  print i > 2 ? i += 1 : i ?? 0;
+}
+print fib(10);
+print 2.0 / 4 + 1;
+print 1.10d + 2n;
+`
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	dumped := DumpJSON(stmts)
+	loaded, err := LoadAST([]byte(dumped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DumpJSON(loaded); got != dumped {
+		t.Errorf("round trip changed the AST:\n%v\nwant\n%v", got, dumped)
+	}
+
+	// Keywords and positions may be left out.
+	loaded, err = LoadAST([]byte(`[
+  {"type": "Var", "name": {"type": "IDENTIFIER", "lexeme": "a"},
+   "initializer": {"type": "Literal", "value": 40}},
+  {"type": "Print", "expression": {"type": "Binary",
+    "left": {"type": "Variable", "name": {"type": "IDENTIFIER", "lexeme": "a"}},
+    "operator": {"type": "PLUS", "lexeme": "+"},
+    "right": {"type": "Literal", "value": 2}}}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	i := NewInterpreter()
+	i.stdout = &out
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(loaded)
+	i.Interpret(loaded)
+	if out.String() != "42\n" {
+		t.Errorf("got output %q, want %q", out.String(), "42\n")
+	}
+
+	errors := []struct {
+		json string
+		want string
+	}{
+		{`{}`, "cannot unmarshal"},
+		{`[{"type": "Loop"}]`, "Unknown statement type Loop."},
+		{`[{"type": "Print"}]`, "Expected an object but got <nil>."},
+		{`[{"type": "Expression", "expression": {"type": "Unary",
+		   "operator": {"type": "BANG_BANG"}, "right": {"type": "Literal"}}}]`, "Unknown token type BANG_BANG."},
+	}
+	for _, test := range errors {
+		if _, err := LoadAST([]byte(test.json)); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("LoadAST(%v) error = %v, want %q", test.json, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Big integers are *big.Int values, written with an n suffix, e.g. 123n.
+// Decimals are exact decimal fractions, written with a d suffix, e.g.
+// 1.10d.
+
+// decimalDivisionScale is the number of digits after the decimal point
+// that quotients are rounded to when they don't terminate, e.g. 1d / 3.
+const decimalDivisionScale = 28
+
+type Decimal struct {
+	value *big.Rat
+	// scale is the number of digits after the decimal point that are
+	// printed, e.g. 2 for 1.10d. value * 10^scale is always an integer.
+	scale int
+}
+
+// parseDecimal parses a decimal literal like "1.10" or "2.5e-3" without
+// the suffix.
+func parseDecimal(text string) *Decimal {
+	value, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil
+	}
+	mantissa, exponent, _ := strings.Cut(strings.ToLower(text), "e")
+	scale := 0
+	if _, fraction, found := strings.Cut(mantissa, "."); found {
+		scale = len(fraction)
+	}
+	if exponent != "" {
+		shift, _ := strconv.Atoi(exponent)
+		scale -= shift
+	}
+	if scale < 0 {
+		scale = 0
+	}
+	return &Decimal{value, scale}
+}
+
+func (d *Decimal) String() string {
+	scaled := new(big.Rat).Mul(d.value, pow10(d.scale))
+	digits := new(big.Int).Abs(scaled.Num()).String()
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	sign := ""
+	if scaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	point := len(digits) - d.scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+func pow10(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
+
+// roundDecimal rounds value half to even to scale digits after the
+// decimal point.
+func roundDecimal(value *big.Rat, scale int) *Decimal {
+	scaled := new(big.Rat).Mul(value, pow10(scale))
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	twice := new(big.Int).Abs(remainder)
+	twice.Lsh(twice, 1)
+	if order := twice.Cmp(scaled.Denom()); order > 0 || order == 0 && quotient.Bit(0) == 1 {
+		if remainder.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return &Decimal{new(big.Rat).Quo(new(big.Rat).SetInt(quotient), pow10(scale)), scale}
+}
+
+// divideDecimals divides exactly if the quotient has at most
+// decimalDivisionScale digits after the decimal point, and rounds
+// otherwise. It keeps at least the scale of the operands.
+func divideDecimals(l, r *Decimal) *Decimal {
+	quotient := new(big.Rat).Quo(l.value, r.value)
+	scale := l.scale
+	if r.scale > scale {
+		scale = r.scale
+	}
+	for scale < decimalDivisionScale && !new(big.Rat).Mul(quotient, pow10(scale)).IsInt() {
+		scale++
+	}
+	return roundDecimal(quotient, scale)
+}
+
+func toBigInt(value any) *big.Int {
+	switch value := value.(type) {
+	case int64:
+		return big.NewInt(value)
+	case *big.Int:
+		return value
+	}
+	return nil
+}
+
+func toDecimal(value any) *Decimal {
+	switch value := value.(type) {
+	case int64:
+		return &Decimal{new(big.Rat).SetInt64(value), 0}
+	case *big.Int:
+		return &Decimal{new(big.Rat).SetInt(value), 0}
+	case *Decimal:
+		return value
+	}
+	return nil
+}
+
+// toRat returns the exact value of a number, or nil for infinities and
+// NaN.
+func toRat(value any) *big.Rat {
+	switch value := value.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(value)
+	case *big.Int:
+		return new(big.Rat).SetInt(value)
+	case *Decimal:
+		return value.value
+	case float64:
+		return new(big.Rat).SetFloat64(value)
+	}
+	return nil
+}
+
+// bigArithmetic returns false for operations whose result is a float,
+// like for int64 operands.
+func (i *Interpreter) bigArithmetic(op Token, l, r *big.Int) (*big.Int, bool) {
+	result := new(big.Int)
+	switch op.tType {
+	case PLUS:
+		return result.Add(l, r), true
+	case MINUS:
+		return result.Sub(l, r), true
+	case STAR:
+		return result.Mul(l, r), true
+	case STAR_STAR:
+		if r.Sign() < 0 {
+			return nil, false
+		}
+		return result.Exp(l, r, nil), true
+	case PERCENT:
+		i.checkDivisor(op, r)
+		return result.Rem(l, r), true
+	case TILDE_SLASH:
+		i.checkDivisor(op, r)
+		return result.Quo(l, r), true
+	}
+	return nil, false
+}
+
+func (i *Interpreter) decimalArithmetic(op Token, l, r *Decimal) *Decimal {
+	maxScale := l.scale
+	if r.scale > maxScale {
+		maxScale = r.scale
+	}
+	switch op.tType {
+	case PLUS:
+		return &Decimal{new(big.Rat).Add(l.value, r.value), maxScale}
+	case MINUS:
+		return &Decimal{new(big.Rat).Sub(l.value, r.value), maxScale}
+	case STAR:
+		return &Decimal{new(big.Rat).Mul(l.value, r.value), l.scale + r.scale}
+	case SLASH:
+		i.checkDivisor(op, r)
+		return divideDecimals(l, r)
+	case TILDE_SLASH:
+		i.checkDivisor(op, r)
+		return &Decimal{truncate(new(big.Rat).Quo(l.value, r.value)), 0}
+	case PERCENT:
+		i.checkDivisor(op, r)
+		quotient := truncate(new(big.Rat).Quo(l.value, r.value))
+		return &Decimal{quotient.Sub(l.value, quotient.Mul(quotient, r.value)), maxScale}
+	}
+	return nil
+}
+
+// decimalPower computes base ** exponent exactly by squaring, like power,
+// except that negative exponents divide like "/". Scales add up like for
+// "*".
+func decimalPower(base *Decimal, exponent int64) *Decimal {
+	result := &Decimal{new(big.Rat).SetInt64(1), 0}
+	if exponent < 0 {
+		base = divideDecimals(result, base)
+		exponent = -exponent
+	}
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result = &Decimal{new(big.Rat).Mul(result.value, base.value), result.scale + base.scale}
+		}
+		exponent >>= 1
+		if exponent > 0 {
+			base = &Decimal{new(big.Rat).Mul(base.value, base.value), 2 * base.scale}
+		}
+	}
+	return result
+}
+
+// truncate rounds value towards zero.
+func truncate(value *big.Rat) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Quo(value.Num(), value.Denom()))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		decimal *Decimal
+		want    string
+	}{
+		{parseDecimal("1.10"), "1.10"},
+		{parseDecimal("0.05"), "0.05"},
+		{parseDecimal("-0.5"), "-0.5"},
+		{parseDecimal("42"), "42"},
+		{roundDecimal(big.NewRat(1, 8), 2), "0.12"},
+		{roundDecimal(big.NewRat(3, 8), 2), "0.38"},
+		{roundDecimal(big.NewRat(-5, 8), 2), "-0.62"},
+		{divideDecimals(parseDecimal("1.00"), parseDecimal("8")), "0.125"},
+		{divideDecimals(parseDecimal("-2"), parseDecimal("3")), "-0.6666666666666666666666666667"},
+	}
+	for _, test := range tests {
+		if got := test.decimal.String(); got != test.want {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+}
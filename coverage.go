@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coverage counts how often the statements of a program are executed and
+// which way its if statements go.
+type Coverage struct {
+	file     string
+	stmts    []Stmt
+	counts   map[Stmt]int
+	branches map[*If]*BranchCoverage
+}
+
+// LineCoverage sums up the statements starting on a line.
+type LineCoverage struct {
+	statements int
+	covered    int
+	count      int
+}
+
+// BranchCoverage counts how often the conditions of the if statements on a
+// line were true and false.
+type BranchCoverage struct {
+	then      int
+	otherwise int
+}
+
+// CoverageProfile is the coverage of a file by line, as written by
+// "golox run --coverage" and read by "golox cover".
+type CoverageProfile struct {
+	file     string
+	lines    map[int]*LineCoverage
+	branches map[int]*BranchCoverage
+}
+
+// NewCoverage starts recording the coverage of stmts, which were parsed
+// from file, when i executes them.
+func NewCoverage(i *Interpreter, file string, stmts []Stmt) *Coverage {
+	c := &Coverage{file, nil, map[Stmt]int{}, map[*If]*BranchCoverage{}}
+	walkStmts(stmts, func(stmt Stmt) {
+		if stmtLine(stmt) > 0 {
+			c.stmts = append(c.stmts, stmt)
+		}
+		if stmt, ok := stmt.(*If); ok {
+			c.branches[stmt] = &BranchCoverage{}
+		}
+	})
+
+	onStmt, onBranch := i.onStmt, i.onBranch
+	i.onStmt = func(stmt Stmt) {
+		c.counts[stmt]++
+		if onStmt != nil {
+			onStmt(stmt)
+		}
+	}
+	i.onBranch = func(stmt *If, taken bool) {
+		if branch := c.branches[stmt]; branch != nil && taken {
+			branch.then++
+		} else if branch != nil {
+			branch.otherwise++
+		}
+		if onBranch != nil {
+			onBranch(stmt, taken)
+		}
+	}
+	return c
+}
+
+// walkStmts calls fn for stmts and the statements nested in them. Of a
+// for loop only the body is walked: the interpreter runs the initializer
+// and increment as part of the for statement, without onStmt.
+func walkStmts(stmts []Stmt, fn func(Stmt)) {
+	for _, stmt := range stmts {
+		if stmt == nil {
+			continue
+		}
+		fn(stmt)
+		switch stmt := stmt.(type) {
+		case *Block:
+			walkStmts(stmt.statements, fn)
+		case *Function:
+			walkStmts(stmt.body, fn)
+		case *For:
+			walkStmts([]Stmt{stmt.body}, fn)
+		case *While:
+			walkStmts([]Stmt{stmt.body}, fn)
+		case *If:
+			walkStmts([]Stmt{stmt.thenBranch, stmt.elseBranch}, fn)
+		}
+	}
+}
+
+// Profile sums up the coverage recorded so far by line.
+func (c *Coverage) Profile() *CoverageProfile {
+	p := &CoverageProfile{c.file, map[int]*LineCoverage{}, map[int]*BranchCoverage{}}
+	for _, stmt := range c.stmts {
+		line := p.line(stmtLine(stmt))
+		line.statements++
+		if count := c.counts[stmt]; count > 0 {
+			line.covered++
+			line.count += count
+		}
+	}
+	for stmt, branch := range c.branches {
+		sum := p.branches[stmt.keyword.line]
+		if sum == nil {
+			sum = &BranchCoverage{}
+			p.branches[stmt.keyword.line] = sum
+		}
+		sum.then += branch.then
+		sum.otherwise += branch.otherwise
+	}
+	return p
+}
+
+func (p *CoverageProfile) line(n int) *LineCoverage {
+	line := p.lines[n]
+	if line == nil {
+		line = &LineCoverage{}
+		p.lines[n] = line
+	}
+	return line
+}
+
+// Write writes the profile in a line based text format:
+//
+//	file <path>
+//	line <line> <statements> <covered> <count>
+//	branch <line> <then> <else>
+func (p *CoverageProfile) Write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "file %v\n", p.file)
+	var lines, branches []int
+	for n := range p.lines {
+		lines = append(lines, n)
+	}
+	for n := range p.branches {
+		branches = append(branches, n)
+	}
+	sort.Ints(lines)
+	sort.Ints(branches)
+	for _, n := range lines {
+		line := p.lines[n]
+		fmt.Fprintf(b, "line %v %v %v %v\n", n, line.statements, line.covered, line.count)
+	}
+	for _, n := range branches {
+		branch := p.branches[n]
+		fmt.Fprintf(b, "branch %v %v %v\n", n, branch.then, branch.otherwise)
+	}
+	return b.Flush()
+}
+
+// ReadCoverageProfile reads a profile written by Write.
+func ReadCoverageProfile(r io.Reader) (*CoverageProfile, error) {
+	p := &CoverageProfile{"", map[int]*LineCoverage{}, map[int]*BranchCoverage{}}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		kind, rest, _ := strings.Cut(scanner.Text(), " ")
+		if kind == "file" {
+			p.file = rest
+			continue
+		}
+		var numbers []int
+		for _, field := range strings.Fields(rest) {
+			number, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("coverage profile line %v: %v", n, err)
+			}
+			numbers = append(numbers, number)
+		}
+		switch {
+		case kind == "line" && len(numbers) == 4:
+			p.lines[numbers[0]] = &LineCoverage{numbers[1], numbers[2], numbers[3]}
+		case kind == "branch" && len(numbers) == 3:
+			p.branches[numbers[0]] = &BranchCoverage{numbers[1], numbers[2]}
+		default:
+			return nil, fmt.Errorf("coverage profile line %v: malformed '%v'", n, scanner.Text())
+		}
+	}
+	return p, scanner.Err()
+}
+
+// Summary returns the share of covered statements and the number of
+// branches taken of all branches.
+func (p *CoverageProfile) Summary() string {
+	statements, covered := 0, 0
+	for _, line := range p.lines {
+		statements += line.statements
+		covered += line.covered
+	}
+	branches, taken := 0, 0
+	for _, branch := range p.branches {
+		branches += 2
+		if branch.then > 0 {
+			taken++
+		}
+		if branch.otherwise > 0 {
+			taken++
+		}
+	}
+	percent := 100.0
+	if statements > 0 {
+		percent = float64(covered) * 100 / float64(statements)
+	}
+	return fmt.Sprintf("%v: %.1f%% of statements, %v of %v branches", p.file, percent, taken, branches)
+}
+
+// coverageLine is one source line of a report.
+type coverageLine struct {
+	Number int
+	Count  string
+	Class  string
+	Text   string
+	Branch string
+}
+
+// reportLines pairs the lines of source with their coverage. Like gcov,
+// lines with statements that never ran are marked with #####.
+func (p *CoverageProfile) reportLines(source string) []coverageLine {
+	var result []coverageLine
+	for n, text := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+		line := coverageLine{Number: n + 1, Text: text}
+		if coverage := p.lines[n+1]; coverage != nil {
+			switch {
+			case coverage.covered == 0:
+				line.Count, line.Class = "#####", "uncovered"
+			case coverage.covered < coverage.statements:
+				line.Count, line.Class = strconv.Itoa(coverage.count), "partial"
+			default:
+				line.Count, line.Class = strconv.Itoa(coverage.count), "covered"
+			}
+		}
+		if branch := p.branches[n+1]; branch != nil {
+			line.Branch = fmt.Sprintf("then %v, else %v", branch.then, branch.otherwise)
+			if (branch.then == 0 || branch.otherwise == 0) && line.Class == "covered" {
+				line.Class = "partial"
+			}
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// Report renders the coverage of source as text, one source line per line.
+func (p *CoverageProfile) Report(source string) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, p.Summary())
+	for _, line := range p.reportLines(source) {
+		fmt.Fprintf(&b, "%6v %5v | %v", line.Count, line.Number, line.Text)
+		if line.Branch != "" {
+			fmt.Fprintf(&b, "  [%v]", line.Branch)
+		}
+		fmt.Fprintln(&b)
+	}
+	return b.String()
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Summary}}</title>
+<style>
+body { font-family: monospace; }
+table { border-collapse: collapse; }
+td { padding: 0 0.5em; white-space: pre; }
+td.count, td.number { text-align: right; color: #888; }
+tr.covered td.text { background: #dfd; }
+tr.partial td.text { background: #ffd; }
+tr.uncovered td.text { background: #fdd; }
+td.branch { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Summary}}</h1>
+<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="count">{{.Count}}</td><td class="number">{{.Number}}</td><td class="text">{{.Text}}</td><td class="branch">{{.Branch}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// HTML renders the coverage of source as a web page.
+func (p *CoverageProfile) HTML(w io.Writer, source string) error {
+	return coverageTemplate.Execute(w, struct {
+		Summary string
+		Lines   []coverageLine
+	}{p.Summary(), p.reportLines(source)})
+}
+
+// coverCommand implements "golox cover [-html file] profile".
+func coverCommand(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ExitOnError)
+	html := flags.String("html", "", "write an HTML report to `file` instead of printing a text report")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: golox cover [-html file] profile")
+		return 64
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer f.Close()
+	profile, err := ReadCoverageProfile(f)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	source, err := ioutil.ReadFile(profile.file)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if *html == "" {
+		fmt.Print(profile.Report(string(source)))
+		return 0
+	}
+	out, err := os.Create(*html)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer out.Close()
+	if err := profile.HTML(out, string(source)); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	source := `fun sign(n) {
+  if (n < 0) return -1;
+  if (n == 0) {
+    return 0;
+  }
+  return 1;
+}
+for (var i = 1; i < 3; i = i + 1) print sign(i);
+`
+	i := NewInterpreter()
+	i.stdout = io.Discard
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	coverage := NewCoverage(i, "sign.lox", stmts)
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(stmts)
+	i.Interpret(stmts)
+
+	profile := coverage.Profile()
+	wantLines := map[int]LineCoverage{
+		1: {1, 1, 1},
+		2: {2, 1, 2},
+		3: {1, 1, 2},
+		4: {1, 0, 0},
+		6: {1, 1, 2},
+		// for and print.
+		8: {2, 2, 3},
+	}
+	for n, want := range wantLines {
+		if got := profile.lines[n]; got == nil || *got != want {
+			t.Errorf("line %v: got %v, want %v", n, got, want)
+		}
+	}
+	if len(profile.lines) != len(wantLines) {
+		t.Errorf("got %v lines with statements, want %v", len(profile.lines), len(wantLines))
+	}
+	if got := *profile.branches[2]; got != (BranchCoverage{0, 2}) {
+		t.Errorf("branch on line 2: got %v", got)
+	}
+
+	var b bytes.Buffer
+	if err := profile.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadCoverageProfile(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, profile) {
+		t.Errorf("ReadCoverageProfile() = %v, want %v", read, profile)
+	}
+
+	report := profile.Report(source)
+	for _, want := range []string{
+		"sign.lox: 75.0% of statements, 2 of 4 branches\n",
+		" #####     4 |     return 0;\n",
+		"     2     2 |   if (n < 0) return -1;  [then 0, else 2]\n",
+		"           5 |   }\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%v", want, report)
+		}
+	}
+
+	if _, err := ReadCoverageProfile(strings.NewReader("file a.lox\nline 1 2\n")); err == nil {
+		t.Error("ReadCoverageProfile() accepted a malformed line")
+	}
+}
+
+func TestCoverageOfForWithoutInitializer(t *testing.T) {
+	source := "var i = 0;\nfor (; i < 3; i = i + 1) print i;\n"
+	i := NewInterpreter()
+	i.stdout = io.Discard
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	coverage := NewCoverage(i, "loop.lox", stmts)
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(stmts)
+	i.Interpret(stmts)
+
+	profile := coverage.Profile()
+	// for and print.
+	if got := *profile.lines[2]; got != (LineCoverage{2, 2, 4}) {
+		t.Errorf("line 2: got %v, want {2 2 4}", got)
+	}
+	if got, want := profile.Summary(), "loop.lox: 100.0% of statements, 0 of 0 branches"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
+func TestCoverageOfForLoop(t *testing.T) {
+	source := "for (var i = 0; i < 3; i = i + 1) print i;\nfor (var j = 0; j < 0; j = j + 1) print j;\n"
+	i := NewInterpreter()
+	i.stdout = io.Discard
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	coverage := NewCoverage(i, "loop.lox", stmts)
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(stmts)
+	i.Interpret(stmts)
+
+	profile := coverage.Profile()
+	// The for statement runs once and its body three times; the
+	// initializer and increment aren't counted separately.
+	if got := *profile.lines[1]; got != (LineCoverage{2, 2, 4}) {
+		t.Errorf("line 1: got %v, want {2 2 4}", got)
+	}
+	if got := *profile.lines[2]; got != (LineCoverage{2, 1, 1}) {
+		t.Errorf("line 2: got %v, want {2 1 1}", got)
+	}
+}
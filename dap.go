@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// The debug adapter speaks the Debug Adapter Protocol over stdio or a
+// TCP connection. The program runs on its own goroutine; whenever the
+// Debugger stops it, the adapter answers requests about its frames and
+// environments until the client resumes.
+
+const dapThreadID = 1
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int64  `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int64  `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+type dapArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+	Breakpoints []struct {
+		Line int `json:"line"`
+	} `json:"breakpoints"`
+	FrameID            int `json:"frameId"`
+	VariablesReference int `json:"variablesReference"`
+}
+
+type dapServer struct {
+	*messageConn
+	seq         int64
+	program     string
+	stopOnEntry bool
+	interpreter *Interpreter
+	debugger    *Debugger
+	resume      chan StepMode
+
+	mu        sync.Mutex
+	isStopped bool
+	// environments are handed out as variablesReference - 1 while the
+	// program is stopped.
+	environments []*Environment
+}
+
+// dapOutput forwards program and error output to the client.
+type dapOutput struct {
+	s        *dapServer
+	category string
+}
+
+func (o dapOutput) Write(p []byte) (int, error) {
+	o.s.event("output", map[string]any{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+// dapCommand implements "golox dap [-listen address]".
+func dapCommand(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	listen := flags.String("listen", "", "serve a single client on this TCP `address` instead of stdio")
+	flags.Parse(args)
+
+	if *listen == "" {
+		serveDAP(os.Stdin, os.Stdout)
+		return 0
+	}
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "Listening on", l.Addr())
+	conn, err := l.Accept()
+	l.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	serveDAP(conn, conn)
+	return 0
+}
+
+// serveDAP handles requests until the client disconnects.
+func serveDAP(in io.Reader, out io.Writer) {
+	s := &dapServer{
+		messageConn: newMessageConn(in, out),
+		interpreter: NewInterpreter(),
+		resume:      make(chan StepMode),
+	}
+	s.interpreter.stdout = dapOutput{s, "stdout"}
+	s.debugger = NewDebugger(s.interpreter, s.stopped)
+
+	for {
+		body, err := s.read()
+		if err != nil {
+			return
+		}
+		var req dapRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			continue
+		}
+		if !s.handle(req) {
+			return
+		}
+	}
+}
+
+func (s *dapServer) respond(req dapRequest, body any) {
+	seq := atomic.AddInt64(&s.seq, 1)
+	s.write(dapResponse{seq, "response", req.Seq, true, req.Command, "", body})
+}
+
+func (s *dapServer) fail(req dapRequest, message string) {
+	seq := atomic.AddInt64(&s.seq, 1)
+	s.write(dapResponse{seq, "response", req.Seq, false, req.Command, message, nil})
+}
+
+func (s *dapServer) event(event string, body any) {
+	seq := atomic.AddInt64(&s.seq, 1)
+	s.write(dapEvent{seq, "event", event, body})
+}
+
+// handle answers a single request and reports whether to keep serving.
+func (s *dapServer) handle(req dapRequest) bool {
+	var args dapArguments
+	json.Unmarshal(req.Arguments, &args)
+
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]any{
+			"supportsConfigurationDoneRequest": true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		s.program = args.Program
+		s.stopOnEntry = args.StopOnEntry
+		s.respond(req, nil)
+	case "setBreakpoints":
+		var lines []int
+		var breakpoints []map[string]any
+		for _, bp := range args.Breakpoints {
+			lines = append(lines, bp.Line)
+			breakpoints = append(breakpoints, map[string]any{"verified": true, "line": bp.Line})
+		}
+		s.debugger.SetBreakpoints(lines)
+		s.respond(req, map[string]any{"breakpoints": breakpoints})
+	case "configurationDone":
+		s.respond(req, nil)
+		if s.stopOnEntry {
+			s.debugger.Step(STEP_IN)
+		}
+		stmts, exitCode := s.load()
+		go s.run(stmts, exitCode)
+	case "threads":
+		s.respond(req, map[string]any{
+			"threads": []map[string]any{{"id": dapThreadID, "name": "main"}},
+		})
+	case "stackTrace":
+		s.respond(req, s.stackTrace())
+	case "scopes":
+		if body, ok := s.scopes(args.FrameID); ok {
+			s.respond(req, body)
+		} else {
+			s.fail(req, "Unknown frame.")
+		}
+	case "variables":
+		if body, ok := s.variables(args.VariablesReference); ok {
+			s.respond(req, body)
+		} else {
+			s.fail(req, "Unknown variables reference.")
+		}
+	case "continue":
+		s.respond(req, map[string]any{"allThreadsContinued": true})
+		s.continueWith(CONTINUE)
+	case "next":
+		s.respond(req, nil)
+		s.continueWith(STEP_OVER)
+	case "stepIn":
+		s.respond(req, nil)
+		s.continueWith(STEP_IN)
+	case "stepOut":
+		s.respond(req, nil)
+		s.continueWith(STEP_OUT)
+	case "pause":
+		s.debugger.Pause()
+		s.respond(req, nil)
+	case "disconnect", "terminate":
+		s.respond(req, nil)
+		return false
+	default:
+		s.fail(req, "Unsupported request "+req.Command+".")
+	}
+	return true
+}
+
+// load reads, parses and resolves the launched program before it runs,
+// so the static passes don't share the global error state with the
+// program's goroutine. Errors go to the client. A non-zero exit code
+// means the program can't run.
+func (s *dapServer) load() (stmts []Stmt, exitCode int) {
+	stderr := dapOutput{s, "stderr"}
+	data, err := ioutil.ReadFile(s.program)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, 1
+	}
+
+	errorHook = func(token *Token, line int, message string) {
+		where := ""
+		if token != nil {
+			where = errorWhere(*token)
+		}
+		fmt.Fprintf(stderr, "[line %v] Error%v: %v\n", line, where, message)
+	}
+	defer func() {
+		errorHook = nil
+		hadError = false
+	}()
+
+	hadError = false
+	stmts = NewParser(NewScanner(string(data)).scanTokens()).parse()
+	if !hadError {
+		resolver := NewResolver(*s.interpreter)
+		resolver.resolveStmts(stmts)
+	}
+	if hadError {
+		return nil, 65
+	}
+	return stmts, 0
+}
+
+// run executes the loaded program like runFile does, or only reports the
+// exit code of load if it failed.
+func (s *dapServer) run(stmts []Stmt, exitCode int) {
+	defer func() {
+		s.event("exited", map[string]any{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+	if exitCode != 0 {
+		return
+	}
+
+	s.interpreter.onError = func(err error) {
+		fmt.Fprintln(dapOutput{s, "stderr"}, err)
+		exitCode = 70
+	}
+	s.interpreter.Interpret(stmts)
+}
+
+// stopped is the Debugger callback. It runs on the program's goroutine
+// and waits for the client to resume.
+func (s *dapServer) stopped(reason string, stmt Stmt) StepMode {
+	if s.stopOnEntry {
+		s.stopOnEntry = false
+		reason = "entry"
+	}
+	s.mu.Lock()
+	s.isStopped = true
+	s.mu.Unlock()
+	s.event("stopped", map[string]any{
+		"reason":            reason,
+		"threadId":          dapThreadID,
+		"allThreadsStopped": true,
+	})
+	return <-s.resume
+}
+
+func (s *dapServer) continueWith(mode StepMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isStopped {
+		return
+	}
+	s.isStopped = false
+	s.environments = nil
+	s.resume <- mode
+}
+
+// frame returns the frame with the given id. Ids count from the bottom of
+// the stack, so they stay valid while the program is stopped.
+func (s *dapServer) frame(id int) (*Frame, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frames := s.interpreter.frames
+	if !s.isStopped || id < 1 || id > len(frames) {
+		return nil, false
+	}
+	return frames[id-1], true
+}
+
+func (s *dapServer) stackTrace() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frames := []map[string]any{}
+	if s.isStopped {
+		for id := len(s.interpreter.frames); id > 0; id-- {
+			frame := s.interpreter.frames[id-1]
+			frames = append(frames, map[string]any{
+				"id":     id,
+				"name":   frame.name,
+				"line":   frame.line,
+				"column": 1,
+				"source": map[string]any{"path": s.program},
+			})
+		}
+	}
+	return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}
+}
+
+func (s *dapServer) scopes(frameID int) (map[string]any, bool) {
+	frame, ok := s.frame(frameID)
+	if !ok {
+		return nil, false
+	}
+	scopes := []map[string]any{}
+	for env := frame.environment; env != nil; env = env.enclosing {
+		name := "Enclosing"
+		switch {
+		case env == s.interpreter.globals:
+			name = "Globals"
+		case env == frame.environment:
+			name = "Locals"
+		}
+		scopes = append(scopes, map[string]any{
+			"name":               name,
+			"variablesReference": s.reference(env),
+			"expensive":          false,
+		})
+	}
+	return map[string]any{"scopes": scopes}, true
+}
+
+func (s *dapServer) reference(env *Environment) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.environments = append(s.environments, env)
+	return len(s.environments)
+}
+
+func (s *dapServer) variables(reference int) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reference < 1 || reference > len(s.environments) {
+		return nil, false
+	}
+	env := s.environments[reference-1]
+	var names []string
+	for name := range env.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	variables := []map[string]any{}
+	for _, name := range names {
+		variables = append(variables, map[string]any{
+			"name":               name,
+			"value":              s.interpreter.stringify(env.values[name]),
+			"variablesReference": 0,
+		})
+	}
+	return map[string]any{"variables": variables}, true
+}
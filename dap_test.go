@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type dapClient struct {
+	t        *testing.T
+	conn     *messageConn
+	seq      int
+	messages chan []byte
+}
+
+func newDAPClient(t *testing.T, in io.Reader, out io.Writer) *dapClient {
+	c := &dapClient{t: t, conn: newMessageConn(in, out), messages: make(chan []byte, 100)}
+	go func() {
+		defer close(c.messages)
+		for {
+			body, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.messages <- body
+		}
+	}()
+	return c
+}
+
+type dapMessage struct {
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func (c *dapClient) send(command string, arguments any) {
+	c.seq++
+	c.conn.write(map[string]any{
+		"seq": c.seq, "type": "request", "command": command, "arguments": arguments,
+	})
+}
+
+// await reads messages until a response to command or the named event
+// arrives and returns its body. Output events are collected in output.
+func (c *dapClient) await(kind, name string, output *strings.Builder) json.RawMessage {
+	for {
+		body, ok := <-c.messages
+		if !ok {
+			c.t.Fatalf("connection closed waiting for %v %v", kind, name)
+		}
+		var msg dapMessage
+		json.Unmarshal(body, &msg)
+		if msg.Event == "output" && output != nil {
+			var out struct{ Output string }
+			json.Unmarshal(msg.Body, &out)
+			output.WriteString(out.Output)
+		}
+		if msg.Type == kind && (msg.Command == name || msg.Event == name) {
+			if kind == "response" && !msg.Success {
+				c.t.Fatalf("%v failed: %s", name, body)
+			}
+			return msg.Body
+		}
+	}
+}
+
+// stackTrace returns the frames as "name:line" from the top.
+func (c *dapClient) stackTrace() string {
+	c.send("stackTrace", map[string]any{"threadId": dapThreadID})
+	var trace struct {
+		StackFrames []struct {
+			Name string
+			Line int
+		}
+	}
+	json.Unmarshal(c.await("response", "stackTrace", nil), &trace)
+	var frames []string
+	for _, frame := range trace.StackFrames {
+		frames = append(frames, fmt.Sprintf("%v:%v", frame.Name, frame.Line))
+	}
+	return strings.Join(frames, " ")
+}
+
+func TestDAP(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "test.lox")
+	code := "var a = 1;\nfun f(x) {\n  var y = x + a;\n  {\n    print y;\n  }\n}\nf(2);\nprint \"done\";\n"
+	os.WriteFile(program, []byte(code), 0644)
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	done := make(chan bool)
+	go func() {
+		serveDAP(serverIn, serverOut)
+		serverOut.Close()
+		close(done)
+	}()
+	c := newDAPClient(t, clientIn, clientOut)
+	var output strings.Builder
+
+	c.send("initialize", map[string]any{})
+	c.await("response", "initialize", nil)
+	c.send("launch", map[string]any{"program": program})
+	c.await("response", "launch", nil)
+	c.send("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": program},
+		"breakpoints": []map[string]any{{"line": 5}},
+	})
+	c.await("response", "setBreakpoints", nil)
+	c.send("configurationDone", nil)
+
+	stopped := c.await("event", "stopped", &output)
+	if !strings.Contains(string(stopped), `"reason":"breakpoint"`) {
+		t.Errorf("stopped = %s, want breakpoint", stopped)
+	}
+
+	if got := c.stackTrace(); got != "f:5 <script>:8" {
+		t.Errorf("stackTrace = %v, want f:5 <script>:8", got)
+	}
+
+	c.send("scopes", map[string]any{"frameId": 2})
+	var scopes struct {
+		Scopes []struct {
+			Name               string
+			VariablesReference int
+		}
+	}
+	json.Unmarshal(c.await("response", "scopes", nil), &scopes)
+	var names []string
+	for _, scope := range scopes.Scopes {
+		names = append(names, scope.Name)
+	}
+	if got := strings.Join(names, ","); got != "Locals,Enclosing,Globals" {
+		t.Fatalf("scopes = %v, want Locals,Enclosing,Globals", got)
+	}
+
+	c.send("variables", map[string]any{"variablesReference": scopes.Scopes[1].VariablesReference})
+	enclosing := string(c.await("response", "variables", nil))
+	if !strings.Contains(enclosing, `"name":"x","value":"2"`) ||
+		!strings.Contains(enclosing, `"name":"y","value":"3"`) {
+		t.Errorf("enclosing = %s, want x = 2 and y = 3", enclosing)
+	}
+
+	c.send("stepOut", map[string]any{"threadId": dapThreadID})
+	c.await("event", "stopped", &output)
+	if got := c.stackTrace(); got != "<script>:9" {
+		t.Errorf("stackTrace after stepOut = %v, want <script>:9", got)
+	}
+
+	c.send("continue", map[string]any{"threadId": dapThreadID})
+	exited := c.await("event", "exited", &output)
+	if !strings.Contains(string(exited), `"exitCode":0`) {
+		t.Errorf("exited = %s, want exit code 0", exited)
+	}
+	if output.String() != "3\ndone\n" {
+		t.Errorf("output = %q, want %q", output.String(), "3\ndone\n")
+	}
+
+	c.send("disconnect", nil)
+	c.await("response", "disconnect", nil)
+	<-done
+}
+
+func TestDAPErrors(t *testing.T) {
+	tests := []struct {
+		code     string
+		exitCode string
+		output   string
+	}{
+		{"print 1;\nprint -\"a\";\n", `"exitCode":70`, "1\nOperand must be a number. \n[line 2]\n"},
+		{"print 1;\nprint (;\n", `"exitCode":65`, "[line 2] Error at ';': Expect expression.\n"},
+	}
+
+	for _, test := range tests {
+		program := filepath.Join(t.TempDir(), "test.lox")
+		os.WriteFile(program, []byte(test.code), 0644)
+
+		clientIn, serverOut := io.Pipe()
+		serverIn, clientOut := io.Pipe()
+		done := make(chan bool)
+		go func() {
+			serveDAP(serverIn, serverOut)
+			serverOut.Close()
+			close(done)
+		}()
+		c := newDAPClient(t, clientIn, clientOut)
+		var output strings.Builder
+
+		c.send("initialize", map[string]any{})
+		c.await("response", "initialize", nil)
+		c.send("launch", map[string]any{"program": program})
+		c.await("response", "launch", nil)
+		c.send("configurationDone", nil)
+		exited := c.await("event", "exited", &output)
+		if !strings.Contains(string(exited), test.exitCode) {
+			t.Errorf("exited = %s, want %v", exited, test.exitCode)
+		}
+		if output.String() != test.output {
+			t.Errorf("output = %q, want %q", output.String(), test.output)
+		}
+
+		c.send("disconnect", nil)
+		c.await("response", "disconnect", nil)
+		<-done
+	}
+	if hadError || hadRuntimeError {
+		t.Errorf("hadError = %v, hadRuntimeError = %v, want the globals untouched", hadError, hadRuntimeError)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const debugHelp = `Commands:
+  break [line]    set a breakpoint, or list breakpoints without a line (b)
+  delete <line>   remove a breakpoint (d)
+  next            run to the next statement, stepping over calls (n)
+  step            run to the next statement, stepping into calls (s)
+  finish          run until the current function returns (fin)
+  continue        run until the next breakpoint (c)
+  print <expr>    evaluate an expression in the current scope (p)
+  locals          show the variables of the current function
+  backtrace       show the call stack (bt)
+  quit            stop debugging (q)`
+
+// cliDebugger is the prompt of "golox debug". The program starts stopped
+// at its first statement.
+type cliDebugger struct {
+	interpreter *Interpreter
+	debugger    *Debugger
+	source      []string
+	in          *bufio.Scanner
+	out         io.Writer
+}
+
+// debugCommand implements "golox debug script".
+func debugCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("Usage: golox debug script")
+		return 64
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return runDebugger(string(data), os.Stdin, os.Stdout)
+}
+
+// runDebugger runs source under the debugger and returns the exit code
+// runFile would have used.
+func runDebugger(source string, in io.Reader, out io.Writer) int {
+	d := &cliDebugger{
+		interpreter: NewInterpreter(),
+		source:      strings.Split(source, "\n"),
+		in:          bufio.NewScanner(in),
+		out:         out,
+	}
+	d.interpreter.stdout = out
+	d.debugger = NewDebugger(d.interpreter, d.stopped)
+
+	hadError = false
+	hadRuntimeError = false
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	if !hadError {
+		resolver := NewResolver(*d.interpreter)
+		resolver.resolveStmts(stmts)
+	}
+	if hadError {
+		return 65
+	}
+
+	d.debugger.Step(STEP_IN)
+	if d.run(stmts) {
+		return 0
+	}
+	if hadRuntimeError {
+		return 70
+	}
+	fmt.Fprintln(d.out, "Program finished.")
+	return 0
+}
+
+// run interprets stmts and reports whether the user quit on the way.
+func (d *cliDebugger) run(stmts []Stmt) (quit bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if _, quit = err.(debugQuit); !quit {
+				panic(err)
+			}
+		}
+	}()
+	d.interpreter.Interpret(stmts)
+	return false
+}
+
+// stopped reads commands until one of them resumes the program. On the
+// end of input the debugger detaches and lets the program run to the end.
+func (d *cliDebugger) stopped(reason string, stmt Stmt) StepMode {
+	line := stmtLine(stmt)
+	if reason == "breakpoint" {
+		fmt.Fprintf(d.out, "Breakpoint at line %v\n", line)
+	}
+	d.list(line)
+
+	for {
+		fmt.Fprint(d.out, "(golox) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.debugger.SetBreakpoints(nil)
+			return CONTINUE
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(d.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+
+		switch command {
+		case "":
+		case "n", "next":
+			return STEP_OVER
+		case "s", "step":
+			return STEP_IN
+		case "fin", "finish":
+			return STEP_OUT
+		case "c", "continue":
+			return CONTINUE
+		case "b", "break":
+			d.setBreakpoint(arg, true)
+		case "d", "delete":
+			d.setBreakpoint(arg, false)
+		case "p", "print":
+			d.print(arg)
+		case "locals":
+			d.locals()
+		case "bt", "backtrace":
+			d.backtrace()
+		case "q", "quit":
+			return QUIT
+		case "h", "help":
+			fmt.Fprintln(d.out, debugHelp)
+		default:
+			fmt.Fprintf(d.out, "Unknown command '%v'. Try 'help'.\n", command)
+		}
+	}
+}
+
+func (d *cliDebugger) list(line int) {
+	text := ""
+	if line > 0 && line <= len(d.source) {
+		text = d.source[line-1]
+	}
+	fmt.Fprintf(d.out, "%v\t%v\n", line, text)
+}
+
+func (d *cliDebugger) setBreakpoint(arg string, set bool) {
+	breakpoints := d.debugger.Breakpoints()
+	if arg == "" && set {
+		if len(breakpoints) == 0 {
+			fmt.Fprintln(d.out, "No breakpoints.")
+		}
+		for _, line := range breakpoints {
+			fmt.Fprintf(d.out, "Breakpoint at line %v\n", line)
+		}
+		return
+	}
+
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		fmt.Fprintf(d.out, "Expected a line number but got '%v'.\n", arg)
+		return
+	}
+	lines := []int{}
+	for _, l := range breakpoints {
+		if l != line {
+			lines = append(lines, l)
+		}
+	}
+	if set {
+		lines = append(lines, line)
+		fmt.Fprintf(d.out, "Breakpoint at line %v\n", line)
+	}
+	d.debugger.SetBreakpoints(lines)
+}
+
+// print evaluates source as an expression in the current environment.
+// The expression is resolved against the environment chain, so locals are
+// found at the same distances the interpreter uses. Its resolutions go to
+// a copy of the interpreter's locals, which is dropped afterwards.
+func (d *cliDebugger) print(source string) {
+	i := d.interpreter
+	locals := i.locals
+	i.locals = make(map[Expr]int, len(locals))
+	for expr, depth := range locals {
+		i.locals[expr] = depth
+	}
+	defer func() {
+		i.locals = locals
+		hadError = false
+		if err := recover(); err != nil {
+			if err, ok := err.(RuntimeError); ok {
+				fmt.Fprintln(d.out, err.msg)
+			}
+		}
+	}()
+
+	parser := NewParser(NewScanner(source).scanTokens())
+	expr := parser.expression()
+	if !parser.isAtEnd() {
+		panic(parser.err(parser.peek(), "Expect end of expression."))
+	}
+	if hadError {
+		return
+	}
+
+	resolver := NewResolver(*i)
+	for _, env := range d.scopes() {
+		scope, constants := map[string]bool{}, map[string]bool{}
+		for name := range env.values {
+			scope[name] = true
+			constants[name] = env.constants[name]
+		}
+		resolver.pushScope(scope, constants)
+	}
+	resolver.resolveExpr(expr)
+	fmt.Fprintln(d.out, i.stringify(i.Evaluate(expr)))
+}
+
+// scopes returns the local environments of the current function from the
+// outermost to the innermost.
+func (d *cliDebugger) scopes() []*Environment {
+	var scopes []*Environment
+	i := d.interpreter
+	for env := i.environment; env != nil && env != i.globals; env = env.enclosing {
+		scopes = append([]*Environment{env}, scopes...)
+	}
+	return scopes
+}
+
+func (d *cliDebugger) locals() {
+	seen := map[string]bool{}
+	scopes := d.scopes()
+	for i := len(scopes) - 1; i >= 0; i-- {
+		var names []string
+		for name := range scopes[i].values {
+			if !seen[name] {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := d.interpreter.stringify(scopes[i].values[name])
+			fmt.Fprintf(d.out, "%v = %v\n", name, value)
+		}
+	}
+	if len(seen) == 0 {
+		fmt.Fprintln(d.out, "No locals.")
+	}
+}
+
+func (d *cliDebugger) backtrace() {
+	frames := d.interpreter.frames
+	for n := len(frames) - 1; n >= 0; n-- {
+		frame := frames[n]
+		fmt.Fprintf(d.out, "#%v %v at line %v\n", len(frames)-1-n, frame.name, frame.line)
+	}
+}
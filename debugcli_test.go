@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDebugger(t *testing.T) {
+	code := `var a = 1;
+fun f(x) {
+  var y = x + a;
+  print y;
+  return y;
+}
+var b = f(2);
+print b;
+`
+	commands := []string{
+		"break 4",
+		"continue",
+		"backtrace",
+		"locals",
+		"print x * y + a",
+		"print x = 5",
+		"print x",
+		"finish",
+		"print b",
+		"continue",
+	}
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(commands, "\n"))
+	if code := runDebugger(code, in, &out); code != 0 {
+		t.Fatalf("runDebugger() = %v, want 0", code)
+	}
+
+	want := []string{
+		"1\tvar a = 1;",
+		"Breakpoint at line 4\n4\t  print y;",
+		"#0 f at line 4\n#1 <script> at line 7\n",
+		"x = 2\ny = 3\n",
+		"(golox) 7\n(golox) 5\n(golox) 5\n",
+		"(golox) 3\n8\tprint b;\n(golox) 3\n(golox) 3\nProgram finished.",
+	}
+	got := out.String()
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output doesn't contain %q:\n%v", w, got)
+		}
+	}
+}
+
+func TestDebuggerQuit(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader("next\nquit\n")
+	if code := runDebugger("print 1;\nprint 2;\n", in, &out); code != 0 {
+		t.Fatalf("runDebugger() = %v, want 0", code)
+	}
+	want := "1\tprint 1;\n(golox) 1\n2\tprint 2;\n(golox) "
+	if got := out.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestDebuggerPrintKeepsLocals(t *testing.T) {
+	var out bytes.Buffer
+	d := &cliDebugger{interpreter: NewInterpreter(), out: &out}
+	i := d.interpreter
+	i.environment = NewEnvironment(i.globals)
+	i.environment.Define("x", int64(2))
+
+	d.print("x + 1")
+	if got := out.String(); got != "3\n" {
+		t.Errorf("print x + 1 = %q, want \"3\\n\"", got)
+	}
+	if len(i.locals) != 0 {
+		t.Errorf("print left %v resolved expressions in the interpreter", len(i.locals))
+	}
+}
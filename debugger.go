@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+type StepMode int
+
+const (
+	CONTINUE StepMode = iota
+	STEP_IN
+	STEP_OVER
+	STEP_OUT
+	PAUSE
+	// QUIT unwinds the program with a debugQuit panic.
+	QUIT
+)
+
+// debugQuit is the panic that ends a program whose user quit debugging.
+// It isn't an error, so Interpret lets it through to the debugger.
+type debugQuit struct{}
+
+// Debugger stops the interpreter at breakpoints and after steps. It is
+// shared by the debug adapter and the command-line debugger, which
+// decide what happens while the program is stopped.
+type Debugger struct {
+	interpreter *Interpreter
+	// stopped is called on the interpreter's goroutine whenever execution
+	// stops before stmt. It blocks until the user resumes and returns how
+	// to go on.
+	stopped func(reason string, stmt Stmt) StepMode
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	mode        StepMode
+	depth       int
+}
+
+func NewDebugger(i *Interpreter, stopped func(reason string, stmt Stmt) StepMode) *Debugger {
+	d := &Debugger{
+		interpreter: i,
+		stopped:     stopped,
+		breakpoints: map[int]bool{},
+	}
+	i.onStmt = d.beforeStmt
+	return d
+}
+
+// SetBreakpoints replaces all breakpoints with the given lines.
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = map[int]bool{}
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+// Breakpoints returns the lines with breakpoints in ascending order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var lines []int
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Step sets how execution continues until the next stop. It reads the
+// interpreter's frames, so it must only be called while the program is
+// stopped or before it starts, e.g. to stop on entry.
+func (d *Debugger) Step(mode StepMode) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mode = mode
+	d.depth = len(d.interpreter.frames)
+}
+
+// Pause stops the program before its next statement. It may be called
+// from any goroutine, since it doesn't need the depth of the running
+// program.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mode = PAUSE
+}
+
+func (d *Debugger) beforeStmt(stmt Stmt) {
+	line := stmtLine(stmt)
+	if line == 0 {
+		return
+	}
+	i := d.interpreter
+	frame := i.frames[len(i.frames)-1]
+	frame.line = line
+	frame.environment = i.environment
+	depth := len(i.frames)
+
+	d.mu.Lock()
+	reason := ""
+	switch {
+	case d.mode == PAUSE:
+		reason = "pause"
+	case d.mode == STEP_IN,
+		d.mode == STEP_OVER && depth <= d.depth,
+		d.mode == STEP_OUT && depth < d.depth:
+		reason = "step"
+	case d.breakpoints[line]:
+		reason = "breakpoint"
+	}
+	d.mu.Unlock()
+	if reason == "" {
+		return
+	}
+
+	mode := d.stopped(reason, stmt)
+	if mode == QUIT {
+		panic(debugQuit{})
+	}
+	d.mu.Lock()
+	d.mode = mode
+	d.depth = depth
+	d.mu.Unlock()
+}
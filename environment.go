@@ -3,6 +3,9 @@ package main
 type Environment struct {
 	enclosing *Environment
 	values    map[string]any
+	// constants holds the names in values that can't be assigned. It is
+	// nil until the first constant is defined.
+	constants map[string]bool
 }
 
 var NewEnvironment func(enclosing *Environment) *Environment = func(enclosing *Environment) *Environment {
@@ -10,6 +13,7 @@ var NewEnvironment func(enclosing *Environment) *Environment = func(enclosing *E
 	return &Environment{
 		enclosing,
 		values,
+		nil,
 	}
 }
 
@@ -17,6 +21,14 @@ func (e *Environment) Define(name string, value any) {
 	e.values[name] = value
 }
 
+func (e *Environment) DefineConstant(name string, value any) {
+	e.Define(name, value)
+	if e.constants == nil {
+		e.constants = map[string]bool{}
+	}
+	e.constants[name] = true
+}
+
 func (e *Environment) Get(name Token) any {
 	if value, ok := e.values[name.lexeme]; !ok {
 		errMsg := "Undefined variable '" + name.lexeme + "'."
@@ -48,6 +60,9 @@ func (e *Environment) Assign(name Token, value any) any {
 		}
 		panic(RuntimeError{name, "Undefined variable '" + name.lexeme + "'."})
 	}
+	if e.constants[name.lexeme] {
+		panic(RuntimeError{name, "Can't assign to constant '" + name.lexeme + "'."})
+	}
 	e.values[name.lexeme] = value
 	return nil
 }
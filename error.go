@@ -5,16 +5,35 @@ import (
 	"strconv"
 )
 
+// errorHook, if set, receives every static error instead of the log. The
+// token is nil for errors that are only known by line, e.g. from the
+// scanner.
+var errorHook func(token *Token, line int, message string)
+
 func errLine(line int, message string) {
+	if errorHook != nil {
+		errorHook(nil, line, message)
+		hadError = true
+		return
+	}
 	report(line, "", message)
 }
 
 func errToken(token Token, message string) {
+	if errorHook != nil {
+		errorHook(&token, token.line, message)
+		hadError = true
+		return
+	}
+	report(token.line, errorWhere(token), message)
+}
+
+// errorWhere describes token as the location of an error message.
+func errorWhere(token Token) string {
 	if token.tType == EOF {
-		report(token.line, " at end", message)
-	} else {
-		report(token.line, " at '"+token.lexeme+"'", message)
+		return " at end"
 	}
+	return " at '" + token.lexeme + "'"
 }
 
 func report(line int, where string, message string) {
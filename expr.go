@@ -4,6 +4,7 @@ type ExprVisitor interface {
 	VisitAssign(b *Assign) any
 	VisitBinary(b *Binary) any
 	VisitCallExpr(c *Call) any
+	VisitConditional(c *Conditional) any
 	VisitGrouping(g *Grouping) any
 	VisitLiteral(l *Literal) any
 	VisitLogical(l *Logical) any
@@ -16,8 +17,14 @@ type Expr interface {
 }
 
 type Assign struct {
-	name  Token
-	value Expr
+	name Token
+	// operator is "=", a compound assignment operator like "+=", or "++"
+	// or "--", for which value is 1. All but "=" combine value with the
+	// current value of the variable.
+	operator Token
+	value    Expr
+	// postfix is set for x++ and x--, which evaluate to the old value.
+	postfix bool
 }
 
 func (a *Assign) Accept(v ExprVisitor) any {
@@ -44,6 +51,18 @@ func (c *Call) Accept(v ExprVisitor) any {
 	return v.VisitCallExpr(c)
 }
 
+// Conditional is the ternary operator cond ? a : b.
+type Conditional struct {
+	condition  Expr
+	question   Token
+	thenBranch Expr
+	elseBranch Expr
+}
+
+func (c *Conditional) Accept(v ExprVisitor) any {
+	return v.VisitConditional(c)
+}
+
 type Grouping struct {
 	Expression Expr
 }
@@ -54,6 +73,7 @@ func (g *Grouping) Accept(v ExprVisitor) any {
 
 type Literal struct {
 	Value any
+	line  int
 }
 
 func (l *Literal) Accept(v ExprVisitor) any {
@@ -86,3 +106,39 @@ type Unary struct {
 func (u *Unary) Accept(v ExprVisitor) any {
 	return v.VisitUnary(u)
 }
+
+// exprLine returns the line of the leftmost token of expr, or 0 for
+// literals, which don't keep their token.
+func exprLine(expr Expr) int {
+	switch expr := expr.(type) {
+	case *Assign:
+		return expr.name.line
+	case *Binary:
+		if line := exprLine(expr.Left); line != 0 {
+			return line
+		}
+		return expr.Operator.line
+	case *Call:
+		if line := exprLine(expr.callee); line != 0 {
+			return line
+		}
+		return expr.paren.line
+	case *Conditional:
+		if line := exprLine(expr.condition); line != 0 {
+			return line
+		}
+		return expr.question.line
+	case *Grouping:
+		return exprLine(expr.Expression)
+	case *Logical:
+		if line := exprLine(expr.left); line != 0 {
+			return line
+		}
+		return expr.operator.line
+	case *Variable:
+		return expr.name.line
+	case *Unary:
+		return expr.Operator.line
+	}
+	return 0
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const indentation = "  "
+
+// FormatError means the formatter got out of sync with the source, i.e.
+// the AST doesn't describe the tokens it was parsed from.
+type FormatError struct {
+	token Token
+	msg   string
+}
+
+func (e FormatError) Error() string {
+	return fmt.Sprintf("%v \n[line %v]", e.msg, e.token.line)
+}
+
+// formatter pretty-prints a parsed program. The AST decides the layout,
+// but every token is copied from the source, so number spellings and the
+// comments collected by the scanner survive.
+type formatter struct {
+	tokens  []Token
+	pending []Token
+	current int
+	// lastLine is the line of the last token or comment written.
+	lastLine  int
+	out       strings.Builder
+	indent    int
+	lineStart bool
+	// spaced means a separator space is due before the next text. It is
+	// dropped if a line break comes first.
+	spaced bool
+}
+
+// Format returns source in canonical style. Programs with syntax errors
+// are not formatted; the errors are reported like in run.
+func Format(source string) (formatted string, err error) {
+	scanner := NewScanner(source)
+	scanner.keepComments = true
+	tokens := scanner.scanTokens()
+	stmts := NewParser(tokens).parse()
+	if hadError {
+		return "", fmt.Errorf("Syntax errors.")
+	}
+
+	f := &formatter{tokens: tokens, pending: scanner.comments, lineStart: true}
+	defer func() {
+		if e := recover(); e != nil {
+			formatError, ok := e.(FormatError)
+			if !ok {
+				panic(e)
+			}
+			err = formatError
+		}
+	}()
+	if scanner.bom {
+		f.out.WriteString("\uFEFF")
+	}
+	f.statements(stmts)
+	f.comments(f.peek())
+	f.newline()
+	return f.out.String(), nil
+}
+
+func (f *formatter) peek() Token {
+	return f.tokens[f.current]
+}
+
+func (f *formatter) write(text string) {
+	if f.lineStart && text != "\n" {
+		f.out.WriteString(strings.Repeat(indentation, f.indent))
+	}
+	if f.spaced && text != "\n" {
+		f.out.WriteString(" ")
+	}
+	f.out.WriteString(text)
+	f.lineStart = text == "\n"
+	f.spaced = false
+}
+
+// space separates the last text from the next one on the same line.
+func (f *formatter) space() {
+	f.spaced = !f.lineStart
+}
+
+func (f *formatter) newline() {
+	if !f.lineStart {
+		f.write("\n")
+	}
+}
+
+// blankLine keeps a single empty line wherever the source had at least
+// one before line.
+func (f *formatter) blankLine(line int) {
+	if f.lastLine > 0 && line > f.lastLine+1 {
+		f.write("\n")
+	}
+}
+
+// emit writes the next source token, which must be one of types.
+// Comments in front of it are written first; if that happens in the
+// middle of a line, the line is continued one level deeper.
+func (f *formatter) emit(types ...TokenType) {
+	token := f.peek()
+	if f.hasComments(token) {
+		if !f.lineStart {
+			f.trailing()
+			f.indent += 2
+			defer func() {
+				f.indent -= 2
+			}()
+		}
+		f.leading(token)
+	}
+
+	for _, typ := range types {
+		if token.tType == typ {
+			f.write(token.lexeme)
+			f.lastLine = token.line
+			if f.current < len(f.tokens)-1 {
+				f.current++
+			}
+			return
+		}
+	}
+	panic(FormatError{token, fmt.Sprintf("Expected %v but got '%v'.", types, token.lexeme)})
+}
+
+// hasComments reports whether comments need to be written before token.
+func (f *formatter) hasComments(token Token) bool {
+	return len(f.pending) > 0 && before(f.pending[0], token)
+}
+
+// comments writes the comments before token on lines of their own.
+func (f *formatter) comments(token Token) {
+	for f.hasComments(token) {
+		f.newline()
+		f.blankLine(f.pending[0].line)
+		f.comment()
+	}
+}
+
+// leading starts a new line for token, keeping the comments and a blank
+// line in front of it.
+func (f *formatter) leading(token Token) {
+	f.comments(token)
+	f.newline()
+	f.blankLine(token.line)
+}
+
+// trailing writes a comment that follows the last token at the end of
+// its line. A comment with more tokens in between on the same line is
+// left to the last of them.
+func (f *formatter) trailing() {
+	if len(f.pending) > 0 && f.pending[0].line == f.lastLine && !f.lineStart && f.hasComments(f.peek()) {
+		f.space()
+		f.comment()
+	}
+}
+
+func (f *formatter) comment() {
+	f.write(strings.TrimRight(f.pending[0].lexeme, " \t\r"))
+	f.lastLine = f.pending[0].line + strings.Count(f.pending[0].lexeme, "\n")
+	f.pending = f.pending[1:]
+	f.write("\n")
+}
+
+func (f *formatter) statements(stmts []Stmt) {
+	for _, stmt := range stmts {
+		f.leading(f.peek())
+		f.statement(stmt)
+		f.trailing()
+		f.newline()
+	}
+}
+
+// block writes "{ stmts }" with the statements indented.
+func (f *formatter) block(stmts []Stmt) {
+	f.emit(LEFT_BRACE)
+	f.trailing()
+	if len(stmts) == 0 && !f.hasComments(f.peek()) {
+		f.emit(RIGHT_BRACE)
+		return
+	}
+	f.indent++
+	f.newline()
+	f.statements(stmts)
+	f.leading(f.peek())
+	f.indent--
+	f.emit(RIGHT_BRACE)
+}
+
+func (f *formatter) statement(stmt Stmt) {
+	stmt.Accept(f)
+}
+
+// body writes the statement controlled by if, while or for.
+func (f *formatter) body(stmt Stmt) {
+	f.space()
+	f.statement(stmt)
+}
+
+func (f *formatter) VisitBlock(b *Block) {
+	f.block(b.statements)
+}
+
+func (f *formatter) VisitExpressionStmt(e *Expression) {
+	f.expression(e.expr)
+	f.emit(SEMICOLON)
+}
+
+func (f *formatter) VisitFunction(fn *Function) {
+	f.emit(FUN)
+	f.space()
+	f.function(fn)
+}
+
+func (f *formatter) function(fn *Function) {
+	f.emit(IDENTIFIER)
+	f.emit(LEFT_PAREN)
+	for i := range fn.params {
+		if i > 0 {
+			f.emit(COMMA)
+			f.space()
+		}
+		f.emit(IDENTIFIER)
+	}
+	f.emit(RIGHT_PAREN)
+	f.space()
+	f.block(fn.body)
+}
+
+func (f *formatter) VisitFor(fr *For) {
+	f.emit(FOR)
+	f.space()
+	f.emit(LEFT_PAREN)
+	if fr.initializer == nil {
+		f.emit(SEMICOLON)
+	} else {
+		f.statement(fr.initializer)
+	}
+	if fr.condition != nil {
+		f.space()
+		f.expression(fr.condition)
+	}
+	f.emit(SEMICOLON)
+	if fr.increment != nil {
+		f.space()
+		f.expression(fr.increment)
+	}
+	f.emit(RIGHT_PAREN)
+	f.body(fr.body)
+}
+
+func (f *formatter) VisitWhile(w *While) {
+	f.emit(WHILE)
+	f.space()
+	f.emit(LEFT_PAREN)
+	f.expression(w.condition)
+	f.emit(RIGHT_PAREN)
+	f.body(w.body)
+}
+
+func (f *formatter) VisitIf(i *If) {
+	f.emit(IF)
+	f.space()
+	f.emit(LEFT_PAREN)
+	f.expression(i.condition)
+	f.emit(RIGHT_PAREN)
+	f.body(i.thenBranch)
+	if i.elseBranch != nil {
+		if _, ok := i.thenBranch.(*Block); ok {
+			f.space()
+		} else {
+			f.trailing()
+			f.newline()
+		}
+		f.emit(ELSE)
+		f.body(i.elseBranch)
+	}
+}
+
+func (f *formatter) VisitPrint(p *Print) {
+	f.emit(PRINT)
+	f.space()
+	f.expression(p.expr)
+	f.emit(SEMICOLON)
+}
+
+func (f *formatter) VisitReturn(r *Return) {
+	f.emit(RETURN)
+	if r.value != nil {
+		f.space()
+		f.expression(r.value)
+	}
+	f.emit(SEMICOLON)
+}
+
+func (f *formatter) VisitVarStmt(v *Var) {
+	f.emit(VAR, CONST)
+	f.space()
+	f.emit(IDENTIFIER)
+	if v.initializer != nil {
+		f.space()
+		f.emit(EQUAL)
+		f.space()
+		f.expression(v.initializer)
+	}
+	f.emit(SEMICOLON)
+}
+
+func (f *formatter) expression(expr Expr) {
+	expr.Accept(f)
+}
+
+func (f *formatter) VisitAssign(a *Assign) any {
+	switch {
+	case a.postfix:
+		f.emit(IDENTIFIER)
+		f.emit(a.operator.tType)
+	case a.operator.tType == PLUS_PLUS || a.operator.tType == MINUS_MINUS:
+		f.emit(a.operator.tType)
+		f.emit(IDENTIFIER)
+	default:
+		f.emit(IDENTIFIER)
+		f.space()
+		f.emit(a.operator.tType)
+		f.space()
+		f.expression(a.value)
+	}
+	return nil
+}
+
+func (f *formatter) VisitBinary(b *Binary) any {
+	if b.Operator.lexeme == "" {
+		// The concatenation of an interpolated string.
+		f.expression(b.Left)
+		f.expression(b.Right)
+		return nil
+	}
+	f.expression(b.Left)
+	f.space()
+	f.emit(b.Operator.tType)
+	f.space()
+	f.expression(b.Right)
+	return nil
+}
+
+func (f *formatter) VisitCallExpr(c *Call) any {
+	f.expression(c.callee)
+	f.emit(LEFT_PAREN)
+	for i, argument := range c.arguments {
+		if i > 0 {
+			f.emit(COMMA)
+			f.space()
+		}
+		f.expression(argument)
+	}
+	f.emit(RIGHT_PAREN)
+	return nil
+}
+
+func (f *formatter) VisitGrouping(g *Grouping) any {
+	f.emit(LEFT_PAREN)
+	f.expression(g.Expression)
+	f.emit(RIGHT_PAREN)
+	return nil
+}
+
+func (f *formatter) VisitLiteral(l *Literal) any {
+	f.emit(NUMBER, STRING, INTERPOLATION, TRUE, FALSE, NIL)
+	return nil
+}
+
+func (f *formatter) VisitConditional(c *Conditional) any {
+	f.expression(c.condition)
+	f.space()
+	f.emit(QUESTION)
+	f.space()
+	f.expression(c.thenBranch)
+	f.space()
+	f.emit(COLON)
+	f.space()
+	f.expression(c.elseBranch)
+	return nil
+}
+
+func (f *formatter) VisitLogical(l *Logical) any {
+	f.expression(l.left)
+	f.space()
+	f.emit(l.operator.tType)
+	f.space()
+	f.expression(l.right)
+	return nil
+}
+
+func (f *formatter) VisitVariableExpr(v *Variable) any {
+	f.emit(IDENTIFIER)
+	return nil
+}
+
+func (f *formatter) VisitUnary(u *Unary) any {
+	if u.Operator.lexeme != "" {
+		f.emit(u.Operator.tType)
+	}
+	if next := f.peek().tType; u.Operator.tType == MINUS && (next == MINUS || next == MINUS_MINUS) {
+		// Keep "- -a" from turning into a decrement.
+		f.space()
+	}
+	f.expression(u.Right)
+	return nil
+}
+
+// fmtCommand implements "golox fmt [-w] [files...]". Without files it
+// formats standard input.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the source files instead of standard output")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return formatFile("<stdin>", data, false)
+	}
+
+	status := 0
+	for _, file := range flags.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if code := formatFile(file, data, *write); code != 0 {
+			status = code
+		}
+	}
+	return status
+}
+
+func formatFile(file string, data []byte, write bool) int {
+	errorHook = func(token *Token, line int, message string) {
+		fmt.Fprintf(os.Stderr, "%v:%v: %v\n", file, line, message)
+	}
+	defer func() {
+		errorHook = nil
+		hadError = false
+	}()
+
+	formatted, err := Format(string(data))
+	if hadError {
+		return 65
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", file, err)
+		return 1
+	}
+
+	if !write {
+		fmt.Print(formatted)
+		return 0
+	}
+	if bytes.Equal(data, []byte(formatted)) {
+		return 0
+	}
+	if err := ioutil.WriteFile(file, []byte(formatted), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
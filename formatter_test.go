@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			"var a=1;   // trailing\nfun   add(x,y){return x+y;}\n",
+			"var a = 1; // trailing\nfun add(x, y) {\n  return x + y;\n}\n",
+		},
+		{
+			"for(var i=0;i<3;i=i+1) print i;\nfor(;;) { }\n",
+			"for (var i = 0; i < 3; i = i + 1) print i;\nfor (;;) {}\n",
+		},
+		{
+			"if (a>0) { print \"pos\"; } else if (a<0) print \"neg\";\n  else print 0.50;\n",
+			"if (a > 0) {\n  print \"pos\";\n} else if (a < 0) print \"neg\";\nelse print 0.50;\n",
+		},
+		{
+			"// header\n\n\n\nwhile(a<10){\n    // inside\n\n    a=a+1;\n}\n// end\n\n",
+			"// header\n\nwhile (a < 10) {\n  // inside\n\n  a = a + 1;\n}\n// end\n",
+		},
+		{
+			"print (1 + 2) * -a;\nvar b = 1 + // why\n2;\n",
+			"print (1 + 2) * -a;\nvar b = 1 + // why\n    2;\n",
+		},
+		{
+			"{\n// only comment\n}\nfun f() {}\n",
+			"{\n  // only comment\n}\nfun f() {}\n",
+		},
+		{
+			"\uFEFFprint  1;\n",
+			"\uFEFFprint 1;\n",
+		},
+		{
+			"const   LIMIT=10 ;\n",
+			"const LIMIT = 10;\n",
+		},
+		{
+			"print \"a${ b+1 }c\\n${\"${d}\"}\";\n",
+			"print \"a${b + 1}c\\n${\"${d}\"}\";\n",
+		},
+		{
+			"a+=1;b --;print - -a;\nfor(var i=0;i<3;++i){}\n",
+			"a += 1;\nb--;\nprint - -a;\nfor (var i = 0; i < 3; ++i) {}\n",
+		},
+		{
+			"var x=a?b:c?d:e;\nprint y??\"none\";\n",
+			"var x = a ? b : c ? d : e;\nprint y ?? \"none\";\n",
+		},
+		{
+			"while (true) { print 1; } // end\n",
+			"while (true) {\n  print 1;\n} // end\n",
+		},
+		{
+			"f(1, // c\n2);\nf(1,\n// own line\n2);\n",
+			"f(1, // c\n    2);\nf(1,\n    // own line\n    2);\n",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := Format(test.source)
+		if err != nil {
+			t.Errorf("Format(%q) failed: %v", test.source, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Format(%q) =\n%v\nwant\n%v", test.source, got, test.want)
+		}
+		if again, _ := Format(got); again != got {
+			t.Errorf("Format isn't idempotent for %q:\n%v", got, again)
+		}
+	}
+}
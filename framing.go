@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// messageConn reads and writes the Content-Length framed JSON messages
+// used by both the language server and the debug adapter. Writes may
+// come from several goroutines.
+type messageConn struct {
+	in  *textproto.Reader
+	out *bufio.Writer
+	mu  sync.Mutex
+}
+
+func newMessageConn(in io.Reader, out io.Writer) *messageConn {
+	return &messageConn{
+		in:  textproto.NewReader(bufio.NewReader(in)),
+		out: bufio.NewWriter(out),
+	}
+}
+
+func (c *messageConn) read() ([]byte, error) {
+	header, err := c.in.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(c.in.R, body)
+	return body, err
+}
+
+func (c *messageConn) write(message any) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		panic(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n", len(body))
+	c.out.Write(body)
+	c.out.Flush()
+}
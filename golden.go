@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The annotations of the Crafting Interpreters test suite.
+var (
+	expectOutputPattern       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectRuntimeErrorPattern = regexp.MustCompile(`// expect runtime error: (.+)`)
+	expectErrorPattern        = regexp.MustCompile(`// (Error.*)`)
+	expectErrorLinePattern    = regexp.MustCompile(`// \[line (\d+)\] (Error.*)`)
+)
+
+type expectedOutput struct {
+	line int
+	text string
+}
+
+// Expectations are what the annotations of a program promise about its
+// run.
+type Expectations struct {
+	output           []expectedOutput
+	errors           []string
+	runtimeError     string
+	runtimeErrorLine int
+	exitCode         int
+}
+
+// ParseExpectations collects the annotations in source.
+func ParseExpectations(source string) Expectations {
+	var e Expectations
+	for n, line := range strings.Split(source, "\n") {
+		n++
+		if m := expectOutputPattern.FindStringSubmatch(line); m != nil {
+			e.output = append(e.output, expectedOutput{n, m[1]})
+		} else if m := expectRuntimeErrorPattern.FindStringSubmatch(line); m != nil {
+			e.runtimeError = m[1]
+			e.runtimeErrorLine = n
+			e.exitCode = 70
+		} else if m := expectErrorLinePattern.FindStringSubmatch(line); m != nil {
+			e.errors = append(e.errors, fmt.Sprintf("[line %v] %v", m[1], m[2]))
+			e.exitCode = 65
+		} else if m := expectErrorPattern.FindStringSubmatch(line); m != nil {
+			e.errors = append(e.errors, fmt.Sprintf("[line %v] %v", n, m[1]))
+			e.exitCode = 65
+		}
+	}
+	return e
+}
+
+// captureErrors runs fn and returns the errors it logged, without
+// timestamps, so they can be compared with the expected ones.
+func captureErrors(fn func()) string {
+	var errs bytes.Buffer
+	flags, writer := log.Flags(), log.Writer()
+	log.SetFlags(0)
+	log.SetOutput(&errs)
+	hadError = false
+	hadRuntimeError = false
+	defer func() {
+		log.SetFlags(flags)
+		log.SetOutput(writer)
+		hadError = false
+		hadRuntimeError = false
+	}()
+	fn()
+	return errs.String()
+}
+
+// runProgram runs source in a fresh interpreter like runFile does, and
+// returns what it printed and the exit code runFile would have used.
+func runProgram(source string) (stdout, stderr string, exitCode int) {
+	var out bytes.Buffer
+	stderr = captureErrors(func() {
+		i := NewInterpreter()
+		i.stdout = &out
+		stmts := NewParser(NewScanner(source).scanTokens()).parse()
+		if !hadError {
+			resolver := NewResolver(*i)
+			resolver.resolveStmts(stmts)
+		}
+		if hadError {
+			exitCode = 65
+			return
+		}
+		i.Interpret(stmts)
+		if hadRuntimeError {
+			exitCode = 70
+		}
+	})
+	return out.String(), stderr, exitCode
+}
+
+// CheckProgram runs source and returns how its run differs from its
+// annotations.
+func CheckProgram(source string) (failures []string) {
+	defer func() {
+		if err := recover(); err != nil {
+			failures = append(failures, fmt.Sprintf("Interpreter crashed: %v", err))
+		}
+	}()
+	e := ParseExpectations(source)
+	stdout, stderr, exitCode := runProgram(source)
+
+	errorLines := lines(stderr)
+	if e.runtimeError != "" {
+		failures = append(failures, checkRuntimeError(e, errorLines)...)
+	} else {
+		failures = append(failures, checkErrors(e, errorLines)...)
+	}
+	if exitCode != e.exitCode {
+		failures = append(failures, fmt.Sprintf("Expected exit code %v and got %v.", e.exitCode, exitCode))
+	}
+
+	outputLines := lines(stdout)
+	for n, line := range outputLines {
+		if n >= len(e.output) {
+			failures = append(failures, fmt.Sprintf("Got output '%v' when none was expected.", line))
+			continue
+		}
+		expected := e.output[n]
+		if line != expected.text {
+			failures = append(failures, fmt.Sprintf("Expected output '%v' on line %v and got '%v'.",
+				expected.text, expected.line, line))
+		}
+	}
+	for _, expected := range e.output[min(len(outputLines), len(e.output)):] {
+		failures = append(failures, fmt.Sprintf("Missing expected output '%v' on line %v.", expected.text, expected.line))
+	}
+	return failures
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// lines splits output into lines without their trailing whitespace.
+func lines(output string) []string {
+	var result []string
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		result = append(result, strings.TrimRight(line, " \t\r"))
+	}
+	if len(result) == 1 && result[0] == "" {
+		return nil
+	}
+	return result
+}
+
+func checkRuntimeError(e Expectations, errorLines []string) []string {
+	if len(errorLines) < 2 {
+		return []string{fmt.Sprintf("Expected runtime error '%v' and got:\n%v",
+			e.runtimeError, strings.Join(errorLines, "\n"))}
+	}
+	var failures []string
+	if errorLines[0] != e.runtimeError {
+		failures = append(failures, fmt.Sprintf("Expected runtime error '%v' and got '%v'.",
+			e.runtimeError, errorLines[0]))
+	}
+	if line := "[line " + strconv.Itoa(e.runtimeErrorLine) + "]"; errorLines[1] != line {
+		failures = append(failures, fmt.Sprintf("Expected '%v' after the runtime error and got '%v'.",
+			line, errorLines[1]))
+	}
+	return failures
+}
+
+func checkErrors(e Expectations, errorLines []string) []string {
+	var failures []string
+	expected := map[string]bool{}
+	for _, err := range e.errors {
+		expected[err] = true
+	}
+	found := map[string]bool{}
+	for _, line := range errorLines {
+		if expected[line] {
+			found[line] = true
+		} else {
+			failures = append(failures, fmt.Sprintf("Unexpected output on stderr: '%v'.", line))
+		}
+	}
+	for _, err := range e.errors {
+		if !found[err] {
+			failures = append(failures, fmt.Sprintf("Missing expected error: %v", err))
+		}
+	}
+	return failures
+}
+
+// testCommand implements "golox test [paths...]". Directories are searched
+// for .lox files; without paths the test-programs directory is used.
+// Programs that declare test_* functions are run as unit tests, all others
+// are checked against their annotations.
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "list passing programs as well")
+	flags.Parse(args)
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"test-programs"}
+	}
+
+	files, err := loxFiles(paths)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	passed, tests, testsPassed := 0, 0, 0
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		var failures []string
+		if isTestFile(string(data)) {
+			output := io.Discard
+			if *verbose {
+				output = os.Stdout
+			}
+			results, err := RunTests(string(data), output)
+			if err != nil {
+				failures = append(failures, err.Error())
+			}
+			for _, result := range results {
+				tests++
+				if result.failure == "" {
+					testsPassed++
+					if *verbose {
+						fmt.Printf("PASS %v: %v\n", file, result.name)
+					}
+				} else {
+					failures = append(failures, result.name+": "+result.failure)
+				}
+			}
+		} else {
+			failures = CheckProgram(string(data))
+		}
+
+		if len(failures) == 0 {
+			passed++
+			if *verbose {
+				fmt.Printf("PASS %v\n", file)
+			}
+			continue
+		}
+		fmt.Printf("FAIL %v\n", file)
+		for _, failure := range failures {
+			fmt.Printf("     %v\n", failure)
+		}
+	}
+
+	fmt.Printf("Passed %v of %v programs.\n", passed, len(files))
+	if tests > 0 {
+		fmt.Printf("Passed %v of %v tests.\n", testsPassed, tests)
+	}
+	if passed != len(files) {
+		return 1
+	}
+	return 0
+}
+
+// loxFiles returns the .lox files in paths, which are files or directories.
+func loxFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if file == path && !d.IsDir() || !d.IsDir() && filepath.Ext(file) == ".lox" {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
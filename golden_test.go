@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// TestPrograms runs the programs in test-programs like golox test does.
+func TestPrograms(t *testing.T) {
+	files, err := loxFiles([]string{"test-programs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isTestFile(string(data)) {
+				for _, failure := range CheckProgram(string(data)) {
+					t.Error(failure)
+				}
+				return
+			}
+			results, err := RunTests(string(data), io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, result := range results {
+				if result.failure != "" {
+					t.Errorf("%v: %v", result.name, result.failure)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckProgram(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"print 1; // expect: 1\n", nil},
+		{
+			"print 1; // expect: 2\nprint 3;\n",
+			[]string{
+				"Expected output '2' on line 1 and got '1'.",
+				"Got output '3' when none was expected.",
+			},
+		},
+		{
+			"// expect: 1\n// expect runtime error: Boom.\n",
+			[]string{
+				"Expected runtime error 'Boom.' and got:\n",
+				"Expected exit code 70 and got 0.",
+				"Missing expected output '1' on line 1.",
+			},
+		},
+		{
+			"print -\"a\"; // expect runtime error: Operand must be a number.\n",
+			nil,
+		},
+		{
+			"var a = 1 // Error at end: Expect ';' after variable declaration.\n",
+			[]string{
+				"Unexpected output on stderr: '[line 2] Error at end: Expected ';' after variable declaration.'.",
+				"Missing expected error: [line 1] Error at end: Expect ';' after variable declaration.",
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := CheckProgram(test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("CheckProgram(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
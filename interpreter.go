@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
-	"strings"
+	"io"
+	"math/big"
+	"os"
 )
 
 type RuntimeError struct {
@@ -18,39 +20,102 @@ func (re RuntimeError) Error() string {
 	return fmt.Sprintf("%v \n[line %v]", re.msg, re.token.line)
 }
 
+// Frame is an active call of a LoxFunction or the top-level script.
+// line and environment are only kept up to date while a statement hook
+// is installed.
+type Frame struct {
+	name        string
+	line        int
+	environment *Environment
+}
+
 type Interpreter struct {
 	environment *Environment
 	globals     *Environment
 	locals      map[Expr]int
+	frames      []*Frame
+	stdout      io.Writer
+	// onStmt, if set, is called before every statement is executed. The
+	// debuggers use it to stop at breakpoints and after steps.
+	onStmt func(stmt Stmt)
+	// onBranch, if set, is called when an if statement has evaluated its
+	// condition.
+	onBranch func(stmt *If, taken bool)
+	// onCall and onReturn, if set, are called when a function starts and
+	// returns. onReturn is also called, with unwinding set and no value,
+	// when a runtime error unwinds the function, so the calls always pair
+	// up.
+	onCall   func(fn LoxFunction, args []any)
+	onReturn func(fn LoxFunction, value any, unwinding bool)
+	// onVariable, if set, is called for every variable access with the
+	// distance to the environment that holds it, or -1 for globals.
+	onVariable func(access VariableAccess, name Token, distance int, value any)
+	// onError, if set, receives the runtime error that stops Interpret
+	// instead of the log and hadRuntimeError.
+	onError func(err error)
 }
 
+type VariableAccess int
+
+const (
+	VARIABLE_READ VariableAccess = iota
+	VARIABLE_ASSIGN
+	VARIABLE_DEFINE
+)
+
 func NewInterpreter() *Interpreter {
 	env := NewEnvironment(nil)
 	i := &Interpreter{
 		environment: env,
 		globals:     env,
 		locals:      map[Expr]int{},
+		stdout:      os.Stdout,
 	}
 
 	i.globals.Define("clock", LoxTime{})
+	i.defineNative("assert", 1, assert)
+	i.defineNative("assertEqual", 2, assertEqual)
+	i.defineNative("int", 1, toInt)
+	i.defineNative("float", 1, toFloat)
 	return i
 }
 
 func (i *Interpreter) Interpret(stmts []Stmt) {
 	defer func() {
 		if err := recover(); err != nil {
-			runtimeError(err.(error))
+			e, ok := err.(error)
+			if !ok {
+				panic(err)
+			}
+			if i.onError != nil {
+				i.onError(e)
+				return
+			}
+			runtimeError(e)
 		}
 	}()
+	defer i.popFrame()
+	i.pushFrame("<script>", i.environment)
 	for _, stmt := range stmts {
 		i.Execute(stmt)
 	}
 }
 
 func (i *Interpreter) Execute(stmt Stmt) {
+	if i.onStmt != nil {
+		i.onStmt(stmt)
+	}
 	stmt.Accept(i)
 }
 
+func (i *Interpreter) pushFrame(name string, env *Environment) {
+	i.frames = append(i.frames, &Frame{name, 0, env})
+}
+
+func (i *Interpreter) popFrame() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
 func (i *Interpreter) VisitBlock(b *Block) {
 	i.executeBlock(b.statements, NewEnvironment(i.environment))
 }
@@ -73,32 +138,31 @@ func (i *Interpreter) Evaluate(expr Expr) any {
 func (i *Interpreter) VisitBinary(b *Binary) any {
 	left := i.Evaluate(b.Left)
 	right := i.Evaluate(b.Right)
+	return i.binary(b.Operator, left, right)
+}
 
-	switch b.Operator.tType {
+// binary applies the binary operator op to two values.
+func (i *Interpreter) binary(op Token, left, right any) any {
+	switch op.tType {
 	case BANG_EQUAL:
-		return left != right
+		return !isEqual(left, right)
 	case EQUAL_EQUAL:
-		return left == right
+		return isEqual(left, right)
 	case GREATER:
-		left, right := i.checkNumberOperands(b.Operator, left, right)
-		return left > right
+		order, ok := i.compare(op, left, right)
+		return ok && order > 0
 	case GREATER_EQUAL:
-		left, right := i.checkNumberOperands(b.Operator, left, right)
-		return left >= right
+		order, ok := i.compare(op, left, right)
+		return ok && order >= 0
 	case LESS:
-		left, right := i.checkNumberOperands(b.Operator, left, right)
-		return left < right
+		order, ok := i.compare(op, left, right)
+		return ok && order < 0
 	case LESS_EQUAL:
-		left, right := i.checkNumberOperands(b.Operator, left, right)
-		return left <= right
-	case MINUS:
-		left, right := i.checkNumberOperands(b.Operator, left, right)
-		return left - right
+		order, ok := i.compare(op, left, right)
+		return ok && order <= 0
 	case PLUS:
-		if left, ok := left.(float64); ok {
-			if right, ok := right.(float64); ok {
-				return left + right
-			}
+		if isNumber(left) && isNumber(right) {
+			return i.arithmetic(op, left, right)
 		}
 		if left, ok := left.(string); ok {
 			if right, ok := right.(string); ok {
@@ -106,13 +170,11 @@ func (i *Interpreter) VisitBinary(b *Binary) any {
 			}
 		}
 		msg := "Operands must be two numbers or two strings."
-		panic(RuntimeError{b.Operator, msg})
-	case SLASH:
-		left, right := i.checkNumberOperands(b.Operator, left, right)
-		return left / right
-	case STAR:
-		left, right := i.checkNumberOperands(b.Operator, left, right)
-		return left * right
+		panic(RuntimeError{op, msg})
+	case MINUS, SLASH, STAR, STAR_STAR, PERCENT, TILDE_SLASH:
+		return i.arithmetic(op, left, right)
+	case AMPERSAND, PIPE, CARET, LESS_LESS, GREATER_GREATER:
+		return i.bitwise(op, left, right)
 	}
 	return nil
 }
@@ -135,7 +197,7 @@ func (i *Interpreter) VisitCallExpr(c *Call) any {
 		msg = fmt.Sprintf(msg, function.Arity(), len(arguments))
 		panic(RuntimeError{c.paren, msg})
 	}
-	return function.Call(i, arguments)
+	return function.Call(i, c.paren, arguments)
 }
 
 func (i *Interpreter) VisitGrouping(g *Grouping) any {
@@ -148,11 +210,16 @@ func (i *Interpreter) VisitLiteral(l *Literal) any {
 
 func (i *Interpreter) VisitLogical(l *Logical) any {
 	left := i.Evaluate(l.left)
-	if l.operator.tType == OR {
+	switch l.operator.tType {
+	case OR:
 		if i.isTruthy(left) {
 			return left
 		}
-	} else {
+	case QUESTION_QUESTION:
+		if left != nil {
+			return left
+		}
+	default:
 		if !i.isTruthy(left) {
 			return left
 		}
@@ -160,17 +227,30 @@ func (i *Interpreter) VisitLogical(l *Logical) any {
 	return i.Evaluate(l.right)
 }
 
+func (i *Interpreter) VisitConditional(c *Conditional) any {
+	if i.isTruthy(i.Evaluate(c.condition)) {
+		return i.Evaluate(c.thenBranch)
+	}
+	return i.Evaluate(c.elseBranch)
+}
+
 func (i *Interpreter) VisitVariableExpr(expr *Variable) any {
 	return i.LookUpVariable(expr.name, expr)
 }
 
 func (i *Interpreter) LookUpVariable(name Token, expr Expr) any {
 	distance, ok := i.locals[expr]
+	var value any
 	if ok != false {
-		return i.environment.GetAt(distance, name.lexeme)
+		value = i.environment.GetAt(distance, name.lexeme)
 	} else {
-		return i.globals.Get(name)
+		value = i.globals.Get(name)
+		distance = -1
 	}
+	if i.onVariable != nil {
+		i.onVariable(VARIABLE_READ, name, distance, value)
+	}
+	return value
 }
 
 func (i *Interpreter) VisitVarStmt(stmt *Var) {
@@ -178,19 +258,67 @@ func (i *Interpreter) VisitVarStmt(stmt *Var) {
 	if stmt.initializer != nil {
 		value = i.Evaluate(stmt.initializer)
 	}
-	i.environment.Define(stmt.name.lexeme, value)
+	i.define(stmt.name, value, stmt.constant)
+}
+
+// define rejects redefining a constant, which only the resolver catches
+// for locals.
+func (i *Interpreter) define(name Token, value any, constant bool) {
+	if i.environment.constants[name.lexeme] {
+		panic(RuntimeError{name, "Can't redefine constant '" + name.lexeme + "'."})
+	}
+	if constant {
+		i.environment.DefineConstant(name.lexeme, value)
+	} else {
+		i.environment.Define(name.lexeme, value)
+	}
+	if i.onVariable != nil {
+		distance := 0
+		if i.environment == i.globals {
+			distance = -1
+		}
+		i.onVariable(VARIABLE_DEFINE, name, distance, value)
+	}
+}
+
+// compoundOperators maps the operators of compound assignments and
+// increments to the binary operators they apply.
+var compoundOperators = map[TokenType]TokenType{
+	PLUS_EQUAL:    PLUS,
+	MINUS_EQUAL:   MINUS,
+	STAR_EQUAL:    STAR,
+	SLASH_EQUAL:   SLASH,
+	PERCENT_EQUAL: PERCENT,
+	PLUS_PLUS:     PLUS,
+	MINUS_MINUS:   MINUS,
 }
 
 func (i *Interpreter) VisitAssign(a *Assign) any {
+	var old any
+	if a.operator.tType != EQUAL {
+		old = i.LookUpVariable(a.name, a)
+	}
 	value := i.Evaluate(a.value)
+	if op, ok := compoundOperators[a.operator.tType]; ok {
+		operator := a.operator
+		operator.tType = op
+		value = i.binary(operator, old, value)
+	}
 
 	distance, ok := i.locals[a]
 	if ok != false {
 		i.environment.AssignAt(distance, a.name, value)
 	} else {
 		i.globals.Assign(a.name, value)
+		distance = -1
+	}
+	if i.onVariable != nil {
+		i.onVariable(VARIABLE_ASSIGN, a.name, distance, value)
 	}
 
+	if a.postfix {
+		return old
+	}
 	return value
 }
 
@@ -200,8 +328,24 @@ func (i *Interpreter) VisitUnary(u *Unary) any {
 	case BANG:
 		return !i.isTruthy(right)
 	case MINUS:
+		switch number := right.(type) {
+		case int64:
+			return -number
+		case *big.Int:
+			return new(big.Int).Neg(number)
+		case *Decimal:
+			return &Decimal{new(big.Rat).Neg(number.value), number.scale}
+		}
 		number := i.checkNumberOperand(u.Operator, right)
 		return -number
+	case TILDE:
+		if integer, ok := right.(int64); ok {
+			return ^integer
+		}
+		integer := i.checkIntegerOperand(u.Operator, right)
+		return new(big.Int).Not(integer)
+	case INTERPOLATION:
+		return i.stringify(right)
 	}
 	return nil
 }
@@ -211,8 +355,36 @@ func (i *Interpreter) VisitExpressionStmt(e *Expression) {
 }
 
 func (i *Interpreter) VisitFunction(stmt *Function) {
-	function := LoxFunction{*stmt, *i.environment}
-	i.environment.Define(stmt.name.lexeme, function)
+	function := LoxFunction{*stmt, i.environment}
+	i.define(stmt.name, function, false)
+}
+
+// VisitFor runs the clauses of the loop itself instead of its desugared
+// form, so statement hooks see the for statement once and then only the
+// statements of its body, not the initializer, the increment or a
+// synthetic while loop. The environments are those of the desugared form,
+// which the resolver assumed: one for the initializer and, if there is an
+// increment, a new one for every iteration.
+func (i *Interpreter) VisitFor(f *For) {
+	previous := i.environment
+	defer func() {
+		i.environment = previous
+	}()
+	if f.initializer != nil {
+		i.environment = NewEnvironment(previous)
+		f.initializer.Accept(i)
+	}
+	loop := i.environment
+	for f.condition == nil || i.isTruthy(i.Evaluate(f.condition)) {
+		if f.increment != nil {
+			i.environment = NewEnvironment(loop)
+		}
+		i.Execute(f.body)
+		if f.increment != nil {
+			i.Evaluate(f.increment)
+		}
+		i.environment = loop
+	}
 }
 
 func (i *Interpreter) VisitWhile(w *While) {
@@ -225,6 +397,9 @@ func (i *Interpreter) VisitWhile(w *While) {
 
 func (i *Interpreter) VisitIf(f *If) {
 	condition := i.Evaluate(f.condition)
+	if i.onBranch != nil {
+		i.onBranch(f, i.isTruthy(condition))
+	}
 	if i.isTruthy(condition) {
 		i.Execute(f.thenBranch)
 	} else if f.elseBranch != nil {
@@ -234,7 +409,7 @@ func (i *Interpreter) VisitIf(f *If) {
 
 func (i *Interpreter) VisitPrint(p *Print) {
 	value := i.Evaluate(p.expr)
-	fmt.Println(i.stringify(value))
+	fmt.Fprintln(i.stdout, i.stringify(value))
 }
 
 func (i *Interpreter) VisitReturn(r *Return) {
@@ -256,7 +431,7 @@ func (i *Interpreter) isTruthy(value any) bool {
 }
 
 func (i *Interpreter) checkNumberOperand(op Token, value any) float64 {
-	if number, ok := value.(float64); !ok {
+	if number, ok := asFloat(value); !ok {
 		panic(RuntimeError{op, "Operand must be a number."})
 	} else {
 		return number
@@ -264,25 +439,32 @@ func (i *Interpreter) checkNumberOperand(op Token, value any) float64 {
 }
 
 func (i *Interpreter) checkNumberOperands(op Token, left, right any) (float64, float64) {
-	l, ok1 := left.(float64)
-	r, ok2 := right.(float64)
+	l, ok1 := asFloat(left)
+	r, ok2 := asFloat(right)
 	if !ok1 || !ok2 {
 		panic(RuntimeError{op, "Operands must be numbers."})
 	}
 	return l, r
 }
 
+func (i *Interpreter) checkIntegerOperand(op Token, value any) *big.Int {
+	integer := toBigInt(value)
+	if integer == nil {
+		i.checkNumberOperand(op, value)
+		panic(RuntimeError{op, "Operand must be an integer."})
+	}
+	return integer
+}
+
 func (i *Interpreter) stringify(value any) string {
 	var text string
 	if runes, ok := value.([]rune); ok {
 		value = string(runes)
 	}
-	text = fmt.Sprintf("%v", value)
-	if _, ok := value.(float64); ok {
-		if strings.HasSuffix(text, ".0") {
-			text = text[:len(text)-2]
-		}
+	if float, ok := value.(float64); ok {
+		return floatLiteral(float)
 	}
+	text = fmt.Sprintf("%v", value)
 	return text
 }
 
@@ -81,9 +81,9 @@ func TestBinary(t *testing.T) {
 	i := Interpreter{}
 	for _, test := range tests {
 		b := Binary{}
-		b.Left = &Literal{test.left}
+		b.Left = &Literal{test.left, 0}
 		b.Operator = test.operator
-		b.Right = &Literal{test.right}
+		b.Right = &Literal{test.right, 0}
 
 		output := i.Evaluate(&b)
 		outValue := reflect.ValueOf(output)
@@ -104,3 +104,68 @@ func TestStringify(t *testing.T) {
 	i := Interpreter{}
 	fmt.Println(i.stringify(int(134235.0)))
 }
+
+func TestBinaryErrors(t *testing.T) {
+	tests := []struct {
+		left     any
+		operator TokenType
+		right    any
+		want     string
+	}{
+		{1.5, AMPERSAND, int64(1), "Operands must be integers."},
+		{int64(1), PIPE, "a", "Operands must be numbers."},
+		{1.0, CARET, int64(1), "Operands must be integers."},
+		{int64(1), LESS_LESS, int64(-1), "Shift count must not be negative."},
+		{int64(1), PERCENT, int64(0), "Division by zero."},
+		{1.0, PERCENT, 0.0, "Division by zero."},
+		{int64(1), TILDE_SLASH, int64(0), "Division by zero."},
+		{"a", STAR_STAR, 2.0, "Operands must be numbers."},
+	}
+
+	i := Interpreter{}
+	for _, test := range tests {
+		b := &Binary{&Literal{test.left, 0}, Token{tType: test.operator}, &Literal{test.right, 0}}
+		func() {
+			defer func() {
+				err, _ := recover().(RuntimeError)
+				if err.msg != test.want {
+					t.Errorf("%v %v %v: got error %q, want %q", test.left, test.operator, test.right, err.msg, test.want)
+				}
+			}()
+			i.Evaluate(b)
+		}()
+	}
+
+	defer func() {
+		if err, _ := recover().(RuntimeError); err.msg != "Operand must be an integer." {
+			t.Errorf("~1.0: got error %q", err.msg)
+		}
+	}()
+	i.Evaluate(&Unary{Token{tType: TILDE}, &Literal{1.0, 0}})
+}
+
+// Closures must share their defining environment, not a copy of it, so
+// tools that compare environments, like the debuggers, can tell which
+// scope a function call runs in.
+func TestClosureSharesEnvironment(t *testing.T) {
+	i := NewInterpreter()
+	stmts := NewParser(NewScanner("fun f() {}\n{ fun g() {} }").scanTokens()).parse()
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(stmts)
+	i.Interpret(stmts)
+	if f := i.globals.values["f"].(LoxFunction); f.closure != i.globals {
+		t.Errorf("closure of f is %p, want the globals %p", f.closure, i.globals)
+	}
+}
+
+func TestNativeErrorsAtCall(t *testing.T) {
+	i := NewInterpreter()
+	paren := Token{tType: RIGHT_PAREN, lexeme: ")", line: 7}
+	defer func() {
+		err, ok := recover().(RuntimeError)
+		if !ok || err.token.line != 7 {
+			t.Errorf("assert(false) failed with %v, want a runtime error on line 7", err)
+		}
+	}()
+	i.globals.values["assert"].(LoxCallable).Call(i, paren, []any{false})
+}
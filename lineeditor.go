@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const historySize = 1000
+
+// lineEditor reads lines from a terminal with emacs style editing keys
+// and a history that is kept in a file between sessions.
+type lineEditor struct {
+	in          *bufio.Reader
+	out         io.Writer
+	fd          int
+	history     []string
+	historyFile string
+}
+
+// newLineEditor returns an editor for the terminal in, or an error if in
+// is not a terminal. historyFile may be empty.
+func newLineEditor(in *os.File, out io.Writer, historyFile string) (*lineEditor, error) {
+	if !isTerminal(int(in.Fd())) {
+		return nil, fmt.Errorf("%v is not a terminal", in.Name())
+	}
+	e := &lineEditor{in: bufio.NewReader(in), out: out, fd: int(in.Fd()), historyFile: historyFile}
+	if data, err := os.ReadFile(historyFile); err == nil {
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			e.remember(line)
+		}
+	}
+	return e, nil
+}
+
+// historyPath returns the file the REPL history is kept in.
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".golox_history")
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	line, err := e.edit(prompt)
+	restore()
+	if err == nil && strings.TrimSpace(line) != "" {
+		e.addHistory(line)
+	}
+	return line, err
+}
+
+// remember adds line to the history in memory, unless it repeats the last
+// entry.
+func (e *lineEditor) remember(line string) bool {
+	if line == "" || len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return false
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > historySize {
+		e.history = e.history[len(e.history)-historySize:]
+	}
+	return true
+}
+
+func (e *lineEditor) addHistory(line string) {
+	if !e.remember(line) || e.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// edit reads keys until Enter with the terminal in raw mode.
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var line []rune
+	cursor := 0
+	// position in the history; len(history) is the line being edited,
+	// which is kept in draft while browsing.
+	position := len(e.history)
+	var draft []rune
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%v%v\x1b[K", prompt, string(line))
+		if back := len(line) - cursor; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%vD", back)
+		}
+	}
+	browse := func(to int) {
+		if to < 0 || to > len(e.history) || to == position {
+			return
+		}
+		if position == len(e.history) {
+			draft = line
+		}
+		position = to
+		if position == len(e.history) {
+			line = draft
+		} else {
+			line = []rune(e.history[position])
+		}
+		cursor = len(line)
+	}
+
+	refresh()
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case 127, 8: // Backspace
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case 1: // Ctrl-A
+			cursor = 0
+		case 5: // Ctrl-E
+			cursor = len(line)
+		case 2: // Ctrl-B
+			if cursor > 0 {
+				cursor--
+			}
+		case 6: // Ctrl-F
+			if cursor < len(line) {
+				cursor++
+			}
+		case 11: // Ctrl-K
+			line = line[:cursor]
+		case 21: // Ctrl-U
+			line = line[cursor:]
+			cursor = 0
+		case 16: // Ctrl-P
+			browse(position - 1)
+		case 14: // Ctrl-N
+			browse(position + 1)
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 27:
+			switch e.escape() {
+			case "[A", "OA":
+				browse(position - 1)
+			case "[B", "OB":
+				browse(position + 1)
+			case "[C", "OC":
+				if cursor < len(line) {
+					cursor++
+				}
+			case "[D", "OD":
+				if cursor > 0 {
+					cursor--
+				}
+			case "[H", "OH", "[1~", "[7~":
+				cursor = 0
+			case "[F", "OF", "[4~", "[8~":
+				cursor = len(line)
+			case "[3~":
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+				}
+			}
+		default:
+			if key >= ' ' {
+				line = append(line[:cursor], append([]rune{key}, line[cursor:]...)...)
+				cursor++
+			}
+		}
+		refresh()
+	}
+}
+
+// escape reads the rest of an escape sequence, e.g. "[A" for the up
+// arrow.
+func (e *lineEditor) escape() string {
+	first, _, err := e.in.ReadRune()
+	if err != nil || first != '[' && first != 'O' {
+		return ""
+	}
+	sequence := []rune{first}
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		sequence = append(sequence, key)
+		if key >= '@' && key <= '~' {
+			return string(sequence)
+		}
+	}
+}
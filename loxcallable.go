@@ -1,6 +1,8 @@
 package main
 
+// LoxCallable is a value that can be called. paren is the location of
+// the call, where errors are reported.
 type LoxCallable interface {
-	Call(interpreter *Interpreter, arguments []any) any
+	Call(interpreter *Interpreter, paren Token, arguments []any) any
 	Arity() int
 }
@@ -2,26 +2,34 @@ package main
 
 type LoxFunction struct {
 	declaration Function
-	closure     Environment
+	closure     *Environment
 }
 
-func (l LoxFunction) Call(i *Interpreter, args []any) (rv any) {
-	env := NewEnvironment(&l.closure)
+func (l LoxFunction) Call(i *Interpreter, paren Token, args []any) (rv any) {
+	env := NewEnvironment(l.closure)
 	for i := 0; i < len(l.declaration.params); i++ {
 		env.Define(l.declaration.params[i].lexeme, args[i])
 	}
 	defer func() {
 		recovered := recover()
-		if returnValue, ok := recovered.(ReturnValue); ok {
+		returnValue, returned := recovered.(ReturnValue)
+		if returned {
 			rv = returnValue.value
-			return
 		}
-
-		if recovered != nil {
+		unwinding := recovered != nil && !returned
+		if i.onReturn != nil {
+			i.onReturn(l, rv, unwinding)
+		}
+		if unwinding {
 			panic(recovered)
 		}
 	}()
 
+	defer i.popFrame()
+	i.pushFrame(l.declaration.name.lexeme, env)
+	if i.onCall != nil {
+		i.onCall(l, args)
+	}
 	i.executeBlock(l.declaration.body, env)
 	return nil
 }
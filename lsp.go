@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// The language server speaks JSON-RPC 2.0 with LSP's Content-Length
+// framing. Documents are analyzed by the scanner, parser and resolver;
+// nothing is ever executed.
+//
+// Positions on the wire are 0-based, tokens are 1-based. Characters are
+// counted in runes rather than UTF-16 code units, which only differs for
+// characters outside the Basic Multilingual Plane.
+
+const (
+	lspParseError     = -32700
+	lspMethodNotFound = -32601
+
+	lspSeverityError = 1
+
+	lspCompletionFunction = 3
+	lspCompletionVariable = 6
+	lspCompletionConstant = 21
+
+	lspSymbolFunction = 12
+	lspSymbolVariable = 13
+	lspSymbolConstant = 14
+)
+
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  any              `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspTextDocumentParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Text     *string     `json:"text"`
+	Position lspPosition `json:"position"`
+}
+
+type lspDocument struct {
+	text    string
+	symbols *SymbolTable
+}
+
+type lspServer struct {
+	*messageConn
+	documents map[string]*lspDocument
+	shutdown  bool
+}
+
+// serveLSP runs the language server until the client sends exit. It
+// returns the process exit code mandated by the protocol.
+func serveLSP(in io.Reader, out io.Writer) int {
+	s := &lspServer{
+		messageConn: newMessageConn(in, out),
+		documents:   map[string]*lspDocument{},
+	}
+	for {
+		body, err := s.read()
+		if err != nil {
+			return 1
+		}
+		var req lspRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, lspParseError, err.Error())
+			continue
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		s.handle(req)
+	}
+}
+
+func (s *lspServer) reply(id *json.RawMessage, result any) {
+	s.write(lspResponse{"2.0", id, result})
+}
+
+func (s *lspServer) replyError(id *json.RawMessage, code int, message string) {
+	s.write(lspErrorResponse{"2.0", id, lspError{code, message}})
+}
+
+func (s *lspServer) notify(method string, params any) {
+	s.write(lspNotification{"2.0", method, params})
+}
+
+func (s *lspServer) handle(req lspRequest) {
+	var params lspTextDocumentParams
+	json.Unmarshal(req.Params, &params)
+	uri := params.TextDocument.URI
+
+	switch req.Method {
+	case "initialize":
+		s.reply(req.ID, map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync": map[string]any{
+					"openClose": true,
+					"change":    1,
+					"save":      map[string]any{"includeText": true},
+				},
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]any{},
+			},
+			"serverInfo": map[string]any{"name": "golox"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		s.reply(req.ID, nil)
+	case "textDocument/didOpen":
+		s.update(uri, params.TextDocument.Text)
+	case "textDocument/didChange":
+		if doc, ok := s.documents[uri]; ok && len(params.ContentChanges) > 0 {
+			doc.text = params.ContentChanges[len(params.ContentChanges)-1].Text
+		}
+	case "textDocument/didSave":
+		if params.Text != nil {
+			s.update(uri, *params.Text)
+		} else if doc, ok := s.documents[uri]; ok {
+			s.update(uri, doc.text)
+		}
+	case "textDocument/didClose":
+		delete(s.documents, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         uri,
+			"diagnostics": []lspDiagnostic{},
+		})
+	case "textDocument/definition":
+		s.reply(req.ID, s.definition(uri, params.Position))
+	case "textDocument/hover":
+		s.reply(req.ID, s.hover(uri, params.Position))
+	case "textDocument/documentSymbol":
+		s.reply(req.ID, s.documentSymbols(uri))
+	case "textDocument/completion":
+		s.reply(req.ID, s.completion(uri, params.Position))
+	default:
+		if req.ID != nil {
+			s.replyError(req.ID, lspMethodNotFound, "Unknown method "+req.Method+".")
+		}
+	}
+}
+
+// update analyzes text and publishes its diagnostics. The symbols of the
+// last document without errors are kept, so navigation keeps working
+// while the user is typing.
+func (s *lspServer) update(uri, text string) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		doc = &lspDocument{}
+		s.documents[uri] = doc
+	}
+	doc.text = text
+	symbols, diagnostics := analyze(text)
+	if symbols != nil {
+		doc.symbols = symbols
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]any{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+// analyze runs the static passes over source and collects their errors.
+// The symbol table is nil if the program doesn't parse.
+func analyze(source string) (*SymbolTable, []lspDiagnostic) {
+	diagnostics := []lspDiagnostic{}
+	errorHook = func(token *Token, line int, message string) {
+		r := lspRange{lspPosition{line - 1, 0}, lspPosition{line, 0}}
+		if token != nil {
+			r = tokenRange(*token)
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{r, lspSeverityError, "golox", message})
+	}
+	defer func() {
+		errorHook = nil
+		hadError = false
+	}()
+
+	hadError = false
+	tokens := NewScanner(source).scanTokens()
+	stmts := NewParser(tokens).parse()
+	if hadError {
+		return nil, diagnostics
+	}
+	resolver := NewResolver(*NewInterpreter())
+	resolver.symbols = NewSymbolTable()
+	resolver.resolveStmts(stmts)
+	resolver.symbols.finish()
+	return resolver.symbols, diagnostics
+}
+
+func tokenRange(token Token) lspRange {
+	start := lspPosition{token.line - 1, token.column - 1}
+	end := start
+	end.Character += len([]rune(token.lexeme))
+	return lspRange{start, end}
+}
+
+func (s *lspServer) symbols(uri string) *SymbolTable {
+	if doc, ok := s.documents[uri]; ok && doc.symbols != nil {
+		return doc.symbols
+	}
+	return NewSymbolTable()
+}
+
+func (s *lspServer) definition(uri string, pos lspPosition) any {
+	symbol := s.symbols(uri).SymbolAt(pos.Line+1, pos.Character+1)
+	if symbol == nil {
+		return nil
+	}
+	return lspLocation{uri, tokenRange(symbol.name)}
+}
+
+func hoverText(symbol *Symbol) string {
+	text := "```lox\n" + signature(symbol) + "\n```"
+	if symbol.doc != "" {
+		text += "\n\n" + symbol.doc
+	}
+	if symbol.kind == FUNCTION_SYMBOL {
+		text += fmt.Sprintf("\n\narity %v", symbol.arity)
+	}
+	return text
+}
+
+func (s *lspServer) hover(uri string, pos lspPosition) any {
+	symbol := s.symbols(uri).SymbolAt(pos.Line+1, pos.Character+1)
+	if symbol == nil {
+		return nil
+	}
+	return map[string]any{
+		"contents": map[string]any{
+			"kind":  "markdown",
+			"value": hoverText(symbol),
+		},
+	}
+}
+
+// signature describes symbol the way it was declared.
+func signature(symbol *Symbol) string {
+	switch symbol.kind {
+	case FUNCTION_SYMBOL:
+		params := make([]string, symbol.arity)
+		for i := range params {
+			params[i] = "_"
+		}
+		fn := symbol.scope.function(symbol.name)
+		if fn != nil && len(fn.symbols) >= symbol.arity {
+			for i, param := range fn.symbols[:symbol.arity] {
+				params[i] = param.name.lexeme
+			}
+		}
+		return "fun " + symbol.name.lexeme + "(" + strings.Join(params, ", ") + ")"
+	case PARAMETER_SYMBOL:
+		return "(parameter) " + symbol.name.lexeme
+	case CONSTANT_SYMBOL:
+		return "const " + symbol.name.lexeme
+	}
+	return "var " + symbol.name.lexeme
+}
+
+// function returns the scope opened for the body of the function
+// declared by name, which starts with its parameters.
+func (s *Scope) function(name Token) *Scope {
+	for _, child := range s.children {
+		if child.first != nil && before(name, *child.first) {
+			return child
+		}
+	}
+	return nil
+}
+
+func (s *lspServer) documentSymbols(uri string) []map[string]any {
+	result := []map[string]any{}
+	for _, symbol := range s.symbols(uri).Symbols() {
+		kind := lspSymbolVariable
+		if symbol.kind == FUNCTION_SYMBOL {
+			kind = lspSymbolFunction
+		} else if symbol.scope.enclosing != nil {
+			// Only global variables are interesting in an outline.
+			continue
+		} else if symbol.kind == CONSTANT_SYMBOL {
+			kind = lspSymbolConstant
+		}
+		result = append(result, map[string]any{
+			"name":     symbol.name.lexeme,
+			"kind":     kind,
+			"location": lspLocation{uri, tokenRange(symbol.name)},
+		})
+	}
+	return result
+}
+
+func (s *lspServer) completion(uri string, pos lspPosition) []map[string]any {
+	items := []map[string]any{}
+	seen := map[string]bool{}
+	for _, symbol := range s.symbols(uri).Visible(pos.Line+1, pos.Character+1) {
+		kind := lspCompletionVariable
+		switch symbol.kind {
+		case FUNCTION_SYMBOL:
+			kind = lspCompletionFunction
+		case CONSTANT_SYMBOL:
+			kind = lspCompletionConstant
+		}
+		seen[symbol.name.lexeme] = true
+		items = append(items, map[string]any{
+			"label":  symbol.name.lexeme,
+			"kind":   kind,
+			"detail": signature(symbol),
+		})
+	}
+
+	var natives []string
+	for name := range NewInterpreter().globals.values {
+		if !seen[name] {
+			natives = append(natives, name)
+		}
+	}
+	sort.Strings(natives)
+	for _, name := range natives {
+		items = append(items, map[string]any{
+			"label":  name,
+			"kind":   lspCompletionFunction,
+			"detail": "<native fn>",
+		})
+	}
+	return items
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func lspFrame(id int, method string, params any) string {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		msg["id"] = id
+	}
+	body, _ := json.Marshal(msg)
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// lspSession feeds the frames to a server and returns its replies by id
+// and its notifications in order.
+func lspSession(t *testing.T, frames ...string) (map[int]json.RawMessage, []json.RawMessage) {
+	var out bytes.Buffer
+	code := serveLSP(strings.NewReader(strings.Join(frames, "")), &out)
+	if code != 0 {
+		t.Fatalf("serveLSP() = %v, want 0", code)
+	}
+
+	replies := map[int]json.RawMessage{}
+	var notifications []json.RawMessage
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		io.ReadFull(r.R, body)
+		var msg struct {
+			ID     int             `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(body, &msg)
+		if msg.ID > 0 {
+			replies[msg.ID] = msg.Result
+		} else {
+			notifications = append(notifications, msg.Params)
+		}
+	}
+	return replies, notifications
+}
+
+func TestLSP(t *testing.T) {
+	uri := "file:///test.lox"
+	code := "var a = 1;\nfun add(x, y) {\n  var z = x;\n  return z + y;\n}\nprint add(a, 2);\n"
+	doc := map[string]any{"uri": uri}
+	at := func(line, character int) map[string]any {
+		return map[string]any{
+			"textDocument": doc,
+			"position":     map[string]any{"line": line, "character": character},
+		}
+	}
+
+	replies, notifications := lspSession(t,
+		lspFrame(1, "initialize", map[string]any{}),
+		lspFrame(0, "textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": code},
+		}),
+		lspFrame(2, "textDocument/definition", at(3, 9)),
+		lspFrame(3, "textDocument/definition", at(5, 7)),
+		lspFrame(4, "textDocument/hover", at(5, 7)),
+		lspFrame(5, "textDocument/documentSymbol", map[string]any{"textDocument": doc}),
+		lspFrame(6, "textDocument/completion", at(3, 2)),
+		lspFrame(0, "textDocument/didSave", map[string]any{
+			"textDocument": doc, "text": "return 1;",
+		}),
+		lspFrame(7, "shutdown", nil),
+		lspFrame(0, "exit", nil),
+	)
+
+	tests := []struct {
+		id   int
+		want []string
+	}{
+		{2, []string{`"start":{"line":2,"character":6}`}},
+		{3, []string{`"start":{"line":1,"character":4}`}},
+		{4, []string{"fun add(x, y)", "arity 2"}},
+		{5, []string{`"name":"a"`, `"name":"add"`}},
+		{6, []string{`"label":"z"`, `"label":"x"`, `"label":"add"`, `"label":"clock"`}},
+	}
+	for _, test := range tests {
+		for _, want := range test.want {
+			if !strings.Contains(string(replies[test.id]), want) {
+				t.Errorf("reply %v = %s, want %s", test.id, replies[test.id], want)
+			}
+		}
+	}
+
+	if len(notifications) != 2 {
+		t.Fatalf("got %v notifications, want 2", len(notifications))
+	}
+	if strings.Contains(string(notifications[0]), "message") {
+		t.Errorf("didOpen diagnostics = %s, want none", notifications[0])
+	}
+	if !strings.Contains(string(notifications[1]), "Can't return from top-level code.") {
+		t.Errorf("didSave diagnostics = %s, want top-level return", notifications[1])
+	}
+}
+
+func TestLSPHoverDocComment(t *testing.T) {
+	uri := "file:///doc.lox"
+	code := "/// Adds x and y.\nfun add(x, y) {\n  return x + y;\n}\nprint add(1, 2);\n"
+	replies, _ := lspSession(t,
+		lspFrame(1, "initialize", map[string]any{}),
+		lspFrame(0, "textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": code},
+		}),
+		lspFrame(2, "textDocument/hover", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": 4, "character": 7},
+		}),
+		lspFrame(3, "shutdown", nil),
+		lspFrame(0, "exit", nil),
+	)
+	for _, want := range []string{"fun add(x, y)", "Adds x and y.", "arity 2"} {
+		if !strings.Contains(string(replies[2]), want) {
+			t.Errorf("hover = %s, want %s", replies[2], want)
+		}
+	}
+}
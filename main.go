@@ -1,23 +1,67 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 )
 
 var hadError = false
 var hadRuntimeError = false
 var interpreter = NewInterpreter()
 
+var dumpAST = flag.String("dump-ast", "", "print the syntax tree of the script as `format` sexpr or json instead of running it")
+var loadAST = flag.Bool("load-ast", false, "read the script as a JSON syntax tree written by -dump-ast=json")
+var dumpTokens = flag.Bool("tokens", false, "print the tokens of the script instead of running it")
+var jsonOutput = flag.Bool("json", false, "print -tokens as JSON")
+
+const usage = `Usage: golox [-dump-ast=sexpr|json] [-load-ast] [-tokens [-json]] [script]
+       golox run [-coverage file] [-profile] [-pprof file] [-trace [-trace-func functions] [-trace-out file]] script
+       golox cover [-html file] profile
+       golox lsp
+       golox dap [-listen address]
+       golox debug script
+       golox fmt [-w] [files...]
+       golox test [-v] [paths...]`
+
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: golox [script]")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		case "cover":
+			os.Exit(coverCommand(os.Args[2:]))
+		case "lsp":
+			os.Exit(serveLSP(os.Stdin, os.Stdout))
+		case "dap":
+			os.Exit(dapCommand(os.Args[2:]))
+		case "debug":
+			os.Exit(debugCommand(os.Args[2:]))
+		case "fmt":
+			os.Exit(fmtCommand(os.Args[2:]))
+		case "test":
+			os.Exit(testCommand(os.Args[2:]))
+		}
+	}
+
+	flag.Usage = func() {
+		fmt.Println(usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *dumpAST != "" && *dumpAST != "sexpr" && *dumpAST != "json" {
+		flag.Usage()
 		os.Exit(64)
-	} else if len(os.Args) == 2 {
-		if err := runFile(os.Args[1]); err != nil {
+	}
+
+	if flag.NArg() > 1 {
+		fmt.Println(usage)
+		os.Exit(64)
+	} else if flag.NArg() == 1 {
+		if err := runFile(flag.Arg(0)); err != nil {
 			os.Exit(1)
 		}
 	} else {
@@ -32,7 +76,42 @@ func runFile(f string) error {
 	if e != nil {
 		return e
 	}
-	run(string(data))
+	if *dumpTokens {
+		tokens := NewScanner(string(data)).scanTokens()
+		if *jsonOutput {
+			fmt.Print(DumpTokensJSON(tokens))
+		} else {
+			fmt.Print(DumpTokens(tokens))
+		}
+		if hadError {
+			os.Exit(65)
+		}
+		return nil
+	}
+
+	var stmts []Stmt
+	if *loadAST {
+		if stmts, e = LoadAST(data); e != nil {
+			log.Println(e)
+			os.Exit(65)
+		}
+	} else {
+		stmts = parse(string(data))
+	}
+	if hadError {
+		os.Exit(65)
+	}
+
+	switch *dumpAST {
+	case "sexpr":
+		fmt.Print(DumpSExpr(stmts))
+		return nil
+	case "json":
+		fmt.Print(DumpJSON(stmts))
+		return nil
+	}
+
+	execute(stmts)
 	if hadError {
 		os.Exit(65)
 	}
@@ -42,35 +121,132 @@ func runFile(f string) error {
 	return nil
 }
 
-func runPrompt() error {
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Printf("> ")
-		scanner.Scan()
-		line := scanner.Text()
-		if (line == "") || scanner.Err() != nil {
-			fmt.Println("Bye!")
-			break
+// runCommand implements "golox run [flags] script", which runs the script
+// like "golox script" with instrumentation.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	coverage := flags.String("coverage", "", "write a coverage profile to `file`")
+	profile := flags.Bool("profile", false, "print the calls and time of every function and the hits of every line")
+	pprof := flags.String("pprof", "", "write a profile for go tool pprof to `file`")
+	trace := flags.Bool("trace", false, "log statements, calls and variable accesses to standard error")
+	traceFuncs := flags.String("trace-func", "", "only trace calls of the comma separated `functions`")
+	traceOut := flags.String("trace-out", "", "write the trace to `file` instead")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: golox run [-coverage file] [-profile] [-pprof file] [-trace [-trace-func functions] [-trace-out file]] script")
+		return 64
+	}
+	file := flags.Arg(0)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	stmts := parse(string(data))
+	if hadError {
+		return 65
+	}
+	var cov *Coverage
+	if *coverage != "" {
+		cov = NewCoverage(interpreter, file, stmts)
+	}
+	var profiler *Profiler
+	if *profile || *pprof != "" {
+		profiler = NewProfiler(interpreter, file)
+	}
+	if *trace || *traceFuncs != "" || *traceOut != "" {
+		out := os.Stderr
+		if *traceOut != "" {
+			if out, err = os.Create(*traceOut); err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			defer out.Close()
+		}
+		var functions []string
+		if *traceFuncs != "" {
+			functions = strings.Split(*traceFuncs, ",")
+		}
+		NewTracer(interpreter, out, functions)
+	}
+	execute(stmts)
+
+	if profiler != nil {
+		profiler.Stop()
+		if *profile {
+			profiler.Report(os.Stderr)
+		}
+		if *pprof != "" {
+			if err := writePprof(*pprof, profiler); err != nil {
+				fmt.Println(err)
+				return 1
+			}
 		}
-		run(line)
-		fmt.Printf("\n")
-		hadError = false
 	}
-	return scanner.Err()
+
+	if cov != nil {
+		if err := writeProfile(*coverage, cov.Profile()); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	if hadError {
+		return 65
+	}
+	if hadRuntimeError {
+		return 70
+	}
+	return 0
 }
 
-func run(script string) {
+func writeProfile(file string, profile *CoverageProfile) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := profile.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writePprof(file string, profiler *Profiler) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := profiler.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runPrompt() error {
+	var lines lineReader = newPlainReader(os.Stdin, os.Stdout)
+	if editor, err := newLineEditor(os.Stdin, os.Stdout, historyPath()); err == nil {
+		lines = editor
+	}
+	return NewREPL(interpreter, os.Stdout).Run(lines)
+}
+
+func parse(script string) []Stmt {
 	scanner := NewScanner(script)
 	tokens := scanner.scanTokens()
 	parser := NewParser(tokens)
-	stmt := parser.parse()
+	return parser.parse()
+}
+
+func execute(stmts []Stmt) {
+	resolver := NewResolver(*interpreter)
+	resolver.resolveStmts(stmts)
 
 	if hadError {
 		return
 	}
-	resolver := NewResolver(*interpreter)
-	resolver.resolveStmts(stmt)
-	interpreter.Interpret(stmt)
+	interpreter.Interpret(stmts)
 }
 
 func runtimeError(err error) {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// NativeFunction is a function implemented in Go. Its errors are reported
+// at the call site, which is passed in as paren.
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    func(i *Interpreter, paren Token, args []any) any
+}
+
+func (n *NativeFunction) Call(i *Interpreter, paren Token, args []any) any {
+	return n.fn(i, paren, args)
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn " + n.name + ">"
+}
+
+func (i *Interpreter) defineNative(name string, arity int, fn func(i *Interpreter, paren Token, args []any) any) {
+	i.globals.Define(name, &NativeFunction{name, arity, fn})
+}
+
+// assert(value) fails unless value is truthy.
+func assert(i *Interpreter, paren Token, args []any) any {
+	if !i.isTruthy(args[0]) {
+		panic(RuntimeError{paren, "Assertion failed: " + i.stringify(args[0]) + " is not true."})
+	}
+	return nil
+}
+
+// assertEqual(actual, expected) fails unless actual == expected would be
+// true.
+func assertEqual(i *Interpreter, paren Token, args []any) any {
+	if !isEqual(args[0], args[1]) {
+		msg := "Expected " + i.stringify(args[1]) + " but got " + i.stringify(args[0]) + "."
+		panic(RuntimeError{paren, msg})
+	}
+	return nil
+}
+
+// toInt implements int(value). Floats are truncated towards zero.
+func toInt(i *Interpreter, paren Token, args []any) any {
+	switch value := args[0].(type) {
+	case int64:
+		return value
+	case *big.Int:
+		if !value.IsInt64() {
+			panic(RuntimeError{paren, "Can't convert " + value.String() + " to an integer."})
+		}
+		return value.Int64()
+	case *Decimal:
+		integer := truncate(value.value).Num()
+		if !integer.IsInt64() {
+			panic(RuntimeError{paren, "Can't convert " + value.String() + " to an integer."})
+		}
+		return integer.Int64()
+	case float64:
+		if math.IsNaN(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+			panic(RuntimeError{paren, "Can't convert " + i.stringify(value) + " to an integer."})
+		}
+		return int64(value)
+	case string:
+		integer, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			panic(RuntimeError{paren, "Can't convert " + strconv.Quote(value) + " to an integer."})
+		}
+		return integer
+	}
+	panic(RuntimeError{paren, "Can't convert " + i.stringify(args[0]) + " to an integer."})
+}
+
+// toFloat implements float(value).
+func toFloat(i *Interpreter, paren Token, args []any) any {
+	if value, ok := asFloat(args[0]); ok {
+		return value
+	}
+	if value, ok := args[0].(string); ok {
+		float, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil {
+			return float
+		}
+		panic(RuntimeError{paren, "Can't convert " + strconv.Quote(value) + " to a float."})
+	}
+	panic(RuntimeError{paren, "Can't convert " + i.stringify(args[0]) + " to a float."})
+}
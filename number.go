@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Numbers are int64, *big.Int, *Decimal or float64. Integer literals are
+// int64 and literals with a fraction are float64.
+//
+// Arithmetic on two integers gives an integer, except for "/", which
+// always divides as floats; "~/" is integer division. Operands of
+// different kinds are converted to the later kind in NumberKind, except
+// that floats and decimals can't be mixed, since that would make
+// decimals inexact. Integer arithmetic wraps around on overflow like Go's
+// int64, e.g. 9223372036854775807 + 1 is -9223372036854775808; big
+// integers don't overflow. Numbers of different kinds with the same value
+// are equal.
+
+type NumberKind int
+
+const (
+	INT_NUMBER NumberKind = iota
+	BIG_NUMBER
+	DECIMAL_NUMBER
+	FLOAT_NUMBER
+)
+
+func numberKind(value any) (NumberKind, bool) {
+	switch value.(type) {
+	case int64:
+		return INT_NUMBER, true
+	case *big.Int:
+		return BIG_NUMBER, true
+	case *Decimal:
+		return DECIMAL_NUMBER, true
+	case float64:
+		return FLOAT_NUMBER, true
+	}
+	return 0, false
+}
+
+func isNumber(value any) bool {
+	_, ok := numberKind(value)
+	return ok
+}
+
+// operandsKind returns the kind both operands are converted to.
+func (i *Interpreter) operandsKind(op Token, left, right any) NumberKind {
+	l, ok1 := numberKind(left)
+	r, ok2 := numberKind(right)
+	if !ok1 || !ok2 {
+		panic(RuntimeError{op, "Operands must be numbers."})
+	}
+	if l == DECIMAL_NUMBER && r == FLOAT_NUMBER || l == FLOAT_NUMBER && r == DECIMAL_NUMBER {
+		panic(RuntimeError{op, "Can't mix floats and decimals."})
+	}
+	if r > l {
+		return r
+	}
+	return l
+}
+
+func asFloat(value any) (float64, bool) {
+	switch value := value.(type) {
+	case int64:
+		return float64(value), true
+	case *big.Int:
+		float, _ := new(big.Float).SetInt(value).Float64()
+		return float, true
+	case *Decimal:
+		float, _ := value.value.Float64()
+		return float, true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+func isEqual(a, b any) bool {
+	aKind, aOk := numberKind(a)
+	bKind, bOk := numberKind(b)
+	if !aOk || !bOk || aKind == bKind && (aKind == INT_NUMBER || aKind == FLOAT_NUMBER) {
+		return a == b
+	}
+	l, r := toRat(a), toRat(b)
+	return l != nil && r != nil && l.Cmp(r) == 0
+}
+
+func isZero(value any) bool {
+	switch value := value.(type) {
+	case int64:
+		return value == 0
+	case *big.Int:
+		return value.Sign() == 0
+	case *Decimal:
+		return value.value.Sign() == 0
+	case float64:
+		return value == 0
+	}
+	return false
+}
+
+// compare returns -1, 0 or 1 if left is less than, equal to or greater
+// than right. NaN compares as unordered, so it only satisfies !=.
+func (i *Interpreter) compare(op Token, left, right any) (int, bool) {
+	switch i.operandsKind(op, left, right) {
+	case INT_NUMBER:
+		l, r := left.(int64), right.(int64)
+		switch {
+		case l < r:
+			return -1, true
+		case l > r:
+			return 1, true
+		}
+		return 0, true
+	case BIG_NUMBER, DECIMAL_NUMBER:
+		return toRat(left).Cmp(toRat(right)), true
+	}
+	l, r := i.checkNumberOperands(op, left, right)
+	switch {
+	case l < r:
+		return -1, true
+	case l > r:
+		return 1, true
+	case l == r:
+		return 0, true
+	}
+	return 0, false
+}
+
+// arithmetic applies +, -, *, /, %, ~/ or ** to two numbers.
+func (i *Interpreter) arithmetic(op Token, left, right any) any {
+	switch i.operandsKind(op, left, right) {
+	case INT_NUMBER:
+		if result, ok := i.integerArithmetic(op, left.(int64), right.(int64)); ok {
+			return result
+		}
+	case BIG_NUMBER:
+		if result, ok := i.bigArithmetic(op, toBigInt(left), toBigInt(right)); ok {
+			return result
+		}
+	case DECIMAL_NUMBER:
+		if op.tType == STAR_STAR {
+			return i.decimalPower(op, toDecimal(left), right)
+		}
+		return i.decimalArithmetic(op, toDecimal(left), toDecimal(right))
+	}
+	l, r := i.checkNumberOperands(op, left, right)
+	switch op.tType {
+	case PLUS:
+		return l + r
+	case MINUS:
+		return l - r
+	case STAR:
+		return l * r
+	case SLASH:
+		return l / r
+	case STAR_STAR:
+		return math.Pow(l, r)
+	case PERCENT:
+		i.checkDivisor(op, r)
+		return math.Mod(l, r)
+	case TILDE_SLASH:
+		i.checkDivisor(op, r)
+		return math.Trunc(l / r)
+	}
+	return nil
+}
+
+// integerArithmetic returns false for operations whose result is a float
+// even for integers, like 1 / 2 and 2 ** -1.
+func (i *Interpreter) integerArithmetic(op Token, l, r int64) (int64, bool) {
+	switch op.tType {
+	case PLUS:
+		return l + r, true
+	case MINUS:
+		return l - r, true
+	case STAR:
+		return l * r, true
+	case STAR_STAR:
+		if r < 0 {
+			return 0, false
+		}
+		return power(l, r), true
+	case PERCENT:
+		i.checkDivisor(op, r)
+		return l % r, true
+	case TILDE_SLASH:
+		i.checkDivisor(op, r)
+		return l / r, true
+	}
+	return 0, false
+}
+
+// power computes base ** exponent by squaring, wrapping around on
+// overflow like the other integer operations.
+func power(base, exponent int64) int64 {
+	result := int64(1)
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+	return result
+}
+
+func (i *Interpreter) decimalPower(op Token, base *Decimal, exponent any) *Decimal {
+	n, ok := exponent.(int64)
+	if integer, isBig := exponent.(*big.Int); isBig && integer.IsInt64() {
+		n, ok = integer.Int64(), true
+	}
+	if !ok {
+		panic(RuntimeError{op, "Exponent of a decimal must be an integer."})
+	}
+	if n < 0 {
+		i.checkDivisor(op, base)
+	}
+	return decimalPower(base, n)
+}
+
+// bitwise applies &, |, ^, << or >> to two integers. Results are big
+// integers if an operand is.
+func (i *Interpreter) bitwise(op Token, left, right any) any {
+	l, r := i.checkIntegerOperands(op, left, right)
+	if op.tType == LESS_LESS || op.tType == GREATER_GREATER {
+		if r.Sign() < 0 {
+			panic(RuntimeError{op, "Shift count must not be negative."})
+		}
+		if !r.IsInt64() {
+			panic(RuntimeError{op, "Shift count is too large."})
+		}
+		count := r.Int64()
+		if left, ok := left.(int64); ok {
+			if op.tType == LESS_LESS {
+				return left << count
+			}
+			return left >> count
+		}
+		if op.tType == LESS_LESS {
+			return new(big.Int).Lsh(l, uint(count))
+		}
+		return new(big.Int).Rsh(l, uint(count))
+	}
+	if left, ok := left.(int64); ok {
+		if right, ok := right.(int64); ok {
+			switch op.tType {
+			case AMPERSAND:
+				return left & right
+			case PIPE:
+				return left | right
+			case CARET:
+				return left ^ right
+			}
+		}
+	}
+	switch op.tType {
+	case AMPERSAND:
+		return new(big.Int).And(l, r)
+	case PIPE:
+		return new(big.Int).Or(l, r)
+	case CARET:
+		return new(big.Int).Xor(l, r)
+	}
+	return nil
+}
+
+// checkIntegerOperands checks that both operands are int64 or big
+// integers, and returns them as big integers.
+func (i *Interpreter) checkIntegerOperands(op Token, left, right any) (*big.Int, *big.Int) {
+	l, r := toBigInt(left), toBigInt(right)
+	if l == nil || r == nil {
+		i.checkNumberOperands(op, left, right)
+		panic(RuntimeError{op, "Operands must be integers."})
+	}
+	return l, r
+}
+
+// checkDivisor rejects zero for % and ~/, where it has no useful IEEE
+// result, and for every division of decimals. "/" still divides floats
+// by zero to infinity.
+func (i *Interpreter) checkDivisor(op Token, divisor any) {
+	if isZero(divisor) {
+		panic(RuntimeError{op, "Division by zero."})
+	}
+}
+
+// numberLiteral formats a number the way it is written in source, e.g.
+// 1.0 for a float and 1n for a big integer.
+func numberLiteral(value any) string {
+	switch value := value.(type) {
+	case *big.Int:
+		return value.String() + "n"
+	case *Decimal:
+		return value.String() + "d"
+	case float64:
+		return floatLiteral(value)
+	}
+	return fmt.Sprint(value)
+}
+
+// floatLiteral formats value so that it reads back as a float, e.g. 1.0
+// instead of 1. Like in JavaScript, only very small and very large values
+// use an exponent, e.g. 1e21 and 1.5e-7.
+func floatLiteral(value float64) string {
+	if abs := math.Abs(value); abs != 0 && !math.IsInf(value, 0) && (abs < 1e-6 || abs >= 1e21) {
+		text := strconv.FormatFloat(value, 'e', -1, 64)
+		mantissa, exponent, _ := strings.Cut(text, "e")
+		shift, _ := strconv.Atoi(exponent)
+		return mantissa + "e" + strconv.Itoa(shift)
+	}
+	text := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.ContainsAny(text, ".IN") {
+		text += ".0"
+	}
+	return text
+}
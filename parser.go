@@ -1,13 +1,14 @@
 // This is the parser implementation of the Lox language
 // It implements the following grammar
 //
-// program    -> decl* EOF;
-// decl       -> funcDecl | varDecl | statement;
-// funcDecl   -> "fun" function
-// function   -> IDENTIFIER "(" parameters? ")" block;
-// paramters  -> IDENTIFIER ("," IDENTIFIER)*;
-// varDecl    -> "var" IDENTIFIER ( "=" expression )? ";";
-// statement  ->  exprStmt   |
+// program     -> decl* EOF;
+// decl        -> funcDecl | varDecl | constDecl | statement;
+// funcDecl    -> "fun" function
+// function    -> IDENTIFIER "(" parameters? ")" block;
+// paramters   -> IDENTIFIER ("," IDENTIFIER)*;
+// varDecl     -> "var" IDENTIFIER ( "=" expression )? ";";
+// constDecl   -> "const" IDENTIFIER "=" expression ";";
+// statement   ->  exprStmt   |
 //
 //		            forStmt    |
 //		            ifStmt     |
@@ -16,36 +17,50 @@
 //	              whileStmt  |
 //	              block;
 //
-// returnStmt -> "return" expression? ";";
-// whileStmt  -> "while" "(" expression ")" statement;
-// ifStmt     -> "if" "(" expression ")" statement ("else" statement )?;
-// block      -> "{" declaration* "}";
-// exprStmt   -> expression ";";
-// forStmt    -> "for" "(" ( varDecl | exprStmt | ";" )
+// returnStmt  -> "return" expression? ";";
+// whileStmt   -> "while" "(" expression ")" statement;
+// ifStmt      -> "if" "(" expression ")" statement ("else" statement )?;
+// block       -> "{" declaration* "}";
+// exprStmt    -> expression ";";
+// forStmt     -> "for" "(" ( varDecl | exprStmt | ";" )
 //
 //		             expression? ";"
 //	               expression? ")" statement;
 //
-// printStmt  -> "print" expression ";";
-// expression -> assignment;
-// assignment -> IDENTIFIER "=" assignment | logic_or;
-// logic_or   -> logic_and ( "or" logic_and)*;
-// logic_and  -> equality ( "and" equality)*;
-// equality   -> comparison ( ( "!=" | "==" ) ) comparison )*;
-// comparison -> term ( ( ">" | "<" | ">=" | "<=" ) term)*;
-// term       -> factor ( ( "+" | "-" ) factor)*;
-// factor     -> unary ( ( "/" | "*" ) unary )*;
-// unary      -> ( "!" | "-") unary | call;
-// call       -> primary ( "(" arguments? ")" )*;
-// arguments  -> expression ( "," expression )*;
-// primary    -> NUMBER   |
+// printStmt   -> "print" expression ";";
+// expression  -> assignment;
+// assignment  -> IDENTIFIER ( "=" | "+=" | "-=" | "*=" | "/=" | "%=" ) assignment | conditional;
+// conditional -> coalesce ( "?" expression ":" conditional )?;
+// coalesce    -> logic_or ( "??" logic_or )*;
+// logic_or    -> logic_and ( "or" logic_and)*;
+// logic_and   -> equality ( "and" equality)*;
+// equality    -> comparison ( ( "!=" | "==" ) ) comparison )*;
+// comparison  -> bitwise_or ( ( ">" | "<" | ">=" | "<=" ) bitwise_or)*;
+// bitwise_or  -> bitwise_xor ( "|" bitwise_xor )*;
+// bitwise_xor -> bitwise_and ( "^" bitwise_and )*;
+// bitwise_and -> shift ( "&" shift )*;
+// shift       -> term ( ( "<<" | ">>" ) term )*;
+// term        -> factor ( ( "+" | "-" ) factor)*;
+// factor      -> unary ( ( "/" | "*" | "%" | "~/" ) unary )*;
+// unary       -> ( "!" | "-" | "~" ) unary | ( "++" | "--" ) IDENTIFIER | exponent;
+// exponent    -> postfix ( "**" unary )?;
+// postfix     -> IDENTIFIER ( "++" | "--" ) | call;
+// call        -> primary ( "(" arguments? ")" )*;
+// arguments   -> expression ( "," expression )*;
+// primary     -> NUMBER   |
 //
 //		         STRING     |
+//		         interpolation |
 //						 "true"     |
 //						 "false"    |
 //						 "nil"      |
 //	           IDENTIFIER |
 //						 "("expression")";
+//
+// An INTERPOLATION token is the part of a string up to a "${", and the
+// STRING after the last one is the rest of it:
+//
+// interpolation -> INTERPOLATION expression ( INTERPOLATION expression )* STRING;
 package main
 
 type ParseError struct {
@@ -59,6 +74,10 @@ func (p *ParseError) Error() string {
 type Parser struct {
 	Tokens  []Token
 	current int
+	// syntax makes the parser record the tokens of every node in spans,
+	// from which a lossless syntax tree is built.
+	syntax bool
+	spans  []span
 }
 
 func NewParser(tokens []Token) *Parser {
@@ -76,16 +95,23 @@ func (p *Parser) parse() []Stmt {
 }
 
 func (p *Parser) declaration() (stmt Stmt) {
+	start := p.current
 	defer func() {
 		if err := recover(); err != nil {
 			p.synchronize()
 			stmt = nil
+			p.node(start, "Error")
 		}
 	}()
 	if p.match(FUN) {
-		return p.function("function")
-	}
-	if p.match(VAR) {
+		doc := p.previous().doc
+		function := p.function("function")
+		function.doc = doc
+		stmt = function
+		p.node(start, stmt)
+		return stmt
+	}
+	if p.match(VAR, CONST) {
 		return p.varDeclaration()
 	}
 	return p.statement()
@@ -109,23 +135,37 @@ func (p *Parser) function(kind string) *Function {
 	p.consume(RIGHT_PAREN, "Expect ')' after parameters.")
 	p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body.")
 	body := p.block()
-	return &Function{name, parameters, body}
+	return &Function{name, parameters, body, ""}
 }
 
-func (p *Parser) varDeclaration() Stmt {
-	errMsg := "Expected identifier after 'var'."
+// varDeclaration expects the caller to have consumed the 'var' or 'const'
+// keyword.
+func (p *Parser) varDeclaration() (stmt Stmt) {
+	start := p.current - 1
+	defer func() {
+		p.node(start, stmt)
+	}()
+	keyword := p.previous()
+	errMsg := "Expected identifier after '" + keyword.lexeme + "'."
 	varID := p.consume(IDENTIFIER, errMsg)
+	constant := keyword.tType == CONST
 	// Check if there is an initializer expression
 	var initializer Expr
 	if p.match(EQUAL) {
 		initializer = p.expression()
+	} else if constant {
+		p.err(p.peek(), "Expect '=' after constant name.")
 	}
 	errMsg = "Expected ';' after variable declaration."
 	p.consume(SEMICOLON, errMsg)
-	return &Var{varID, initializer}
+	return &Var{varID, initializer, constant}
 }
 
-func (p *Parser) statement() Stmt {
+func (p *Parser) statement() (stmt Stmt) {
+	start := p.current
+	defer func() {
+		p.node(start, stmt)
+	}()
 	if p.match(PRINT) {
 		return p.printStatement()
 	}
@@ -158,9 +198,10 @@ func (p *Parser) block() []Stmt {
 }
 
 func (p *Parser) printStatement() Stmt {
+	keyword := p.previous()
 	value := p.expression()
 	p.consume(SEMICOLON, "Expect ';' after value.")
-	return &Print{value}
+	return &Print{keyword, value}
 }
 
 func (p *Parser) expressionStmt() Stmt {
@@ -180,14 +221,16 @@ func (p *Parser) returnStmt() Stmt {
 }
 
 func (p *Parser) whileStmt() Stmt {
+	keyword := p.previous()
 	p.consume(LEFT_PAREN, "Expected '(' after while statement.")
 	condition := p.expression()
 	p.consume(RIGHT_PAREN, "Expected ')' after while condition.")
 	body := p.statement()
-	return &While{condition, body}
+	return &While{keyword, condition, body}
 }
 
 func (p *Parser) forStmt() Stmt {
+	keyword := p.previous()
 	p.consume(LEFT_PAREN, "Expected '(' after 'for'.")
 	var initializer Stmt
 	if p.match(SEMICOLON) {
@@ -212,28 +255,11 @@ func (p *Parser) forStmt() Stmt {
 	p.consume(RIGHT_PAREN, "Expect ')' after for clause.")
 
 	body := p.statement()
-
-	if increment != nil {
-		body = &Block{
-			[]Stmt{body, &Expression{increment}},
-		}
-	}
-
-	if condition == nil {
-		condition = &Literal{true}
-	}
-	body = &While{condition, body}
-
-	if initializer != nil {
-		body = &Block{
-			[]Stmt{initializer, body},
-		}
-	}
-
-	return body
+	return NewFor(keyword, initializer, condition, increment, body)
 }
 
 func (p *Parser) ifStmt() Stmt {
+	keyword := p.previous()
 	p.consume(LEFT_PAREN, "Expected opening '(' after 'if'.")
 	expr := p.expression()
 	p.consume(RIGHT_PAREN, "Expected closing ')' after 'if' expression.")
@@ -242,7 +268,7 @@ func (p *Parser) ifStmt() Stmt {
 	if p.match(ELSE) {
 		elseStmt = p.statement()
 	}
-	return &If{expr, stmt, elseStmt}
+	return &If{keyword, expr, stmt, elseStmt}
 }
 
 func (p *Parser) expression() Expr {
@@ -250,55 +276,94 @@ func (p *Parser) expression() Expr {
 }
 
 func (p *Parser) assignment() Expr {
-	expr := p.logic_or()
-	if p.match(EQUAL) {
+	start := p.current
+	expr := p.conditional()
+	if p.match(EQUAL, PLUS_EQUAL, MINUS_EQUAL, STAR_EQUAL, SLASH_EQUAL, PERCENT_EQUAL) {
 		equals := p.previous()
 		value := p.assignment()
 		if expr, ok := expr.(*Variable); !ok {
 			panic(p.err(equals, "Invalid assignment target."))
 		} else {
-			return &Assign{expr.name, value}
+			assign := &Assign{expr.name, equals, value, false}
+			p.node(start, assign)
+			return assign
 		}
 	}
 	return expr
 }
 
+// conditional is right-associative, so a ? b : c ? d : e is
+// a ? b : (c ? d : e).
+func (p *Parser) conditional() Expr {
+	start := p.current
+	expr := p.coalesce()
+	if p.match(QUESTION) {
+		question := p.previous()
+		thenBranch := p.expression()
+		p.consume(COLON, "Expect ':' after then branch of conditional expression.")
+		elseBranch := p.conditional()
+		expr = &Conditional{expr, question, thenBranch, elseBranch}
+		p.node(start, expr)
+	}
+	return expr
+}
+
+func (p *Parser) coalesce() Expr {
+	start := p.current
+	expr := p.logic_or()
+	for p.match(QUESTION_QUESTION) {
+		operator := p.previous()
+		right := p.logic_or()
+		expr = &Logical{expr, operator, right}
+		p.node(start, expr)
+	}
+	return expr
+}
+
 func (p *Parser) logic_or() Expr {
+	start := p.current
 	expr := p.logic_and()
 	for p.match(OR) {
 		operator := p.previous()
 		right := p.logic_and()
 		expr = &Logical{expr, operator, right}
+		p.node(start, expr)
 	}
 	return expr
 }
 
 func (p *Parser) logic_and() Expr {
+	start := p.current
 	expr := p.equality()
 	for p.match(AND) {
 		operator := p.previous()
 		right := p.equality()
 		expr = &Logical{expr, operator, right}
+		p.node(start, expr)
 	}
 	return expr
 }
 
 func (p *Parser) equality() Expr {
+	start := p.current
 	expr := p.comparison()
 	for p.match(BANG_EQUAL, EQUAL_EQUAL) {
 		op := p.previous()
 		right := p.comparison()
 		expr = &Binary{expr, op, right}
+		p.node(start, expr)
 	}
 	return expr
 }
 
 func (p *Parser) comparison() Expr {
-	expr := p.term()
+	start := p.current
+	expr := p.bitwise_or()
 	for p.match(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL) {
 		op := p.previous()
-		right := p.term()
+		right := p.bitwise_or()
 		expr = &Binary{expr, op, right}
+		p.node(start, expr)
 	}
 	return expr
 }
@@ -320,41 +385,137 @@ func (p *Parser) match(types ...TokenType) bool {
 	return false
 }
 
+func (p *Parser) bitwise_or() Expr {
+	start := p.current
+	expr := p.bitwise_xor()
+	for p.match(PIPE) {
+		op := p.previous()
+		right := p.bitwise_xor()
+		expr = &Binary{expr, op, right}
+		p.node(start, expr)
+	}
+	return expr
+}
+
+func (p *Parser) bitwise_xor() Expr {
+	start := p.current
+	expr := p.bitwise_and()
+	for p.match(CARET) {
+		op := p.previous()
+		right := p.bitwise_and()
+		expr = &Binary{expr, op, right}
+		p.node(start, expr)
+	}
+	return expr
+}
+
+func (p *Parser) bitwise_and() Expr {
+	start := p.current
+	expr := p.shift()
+	for p.match(AMPERSAND) {
+		op := p.previous()
+		right := p.shift()
+		expr = &Binary{expr, op, right}
+		p.node(start, expr)
+	}
+	return expr
+}
+
+func (p *Parser) shift() Expr {
+	start := p.current
+	expr := p.term()
+	for p.match(LESS_LESS, GREATER_GREATER) {
+		op := p.previous()
+		right := p.term()
+		expr = &Binary{expr, op, right}
+		p.node(start, expr)
+	}
+	return expr
+}
+
 func (p *Parser) term() Expr {
+	start := p.current
 	expr := p.factor()
 	for p.match(PLUS, MINUS) {
 		op := p.previous()
 		right := p.factor()
 		expr = &Binary{expr, op, right}
+		p.node(start, expr)
 	}
 	return expr
 }
 
 func (p *Parser) factor() Expr {
+	start := p.current
 	expr := p.unary()
-	for p.match(SLASH, STAR) {
+	for p.match(SLASH, STAR, PERCENT, TILDE_SLASH) {
 		op := p.previous()
 		right := p.unary()
 		expr = &Binary{expr, op, right}
+		p.node(start, expr)
 	}
 	return expr
 }
 
 func (p *Parser) unary() Expr {
-	if p.match(MINUS, BANG) {
+	start := p.current
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
 		op := p.previous()
-		expr := p.unary()
-		return &Unary{op, expr}
+		target, ok := p.unary().(*Variable)
+		if !ok {
+			panic(p.err(op, "Invalid increment target."))
+		}
+		expr := &Assign{target.name, op, &Literal{int64(1), op.line}, false}
+		p.node(start, expr)
+		return expr
+	}
+	if p.match(MINUS, BANG, TILDE) {
+		op := p.previous()
+		right := p.unary()
+		expr := &Unary{op, right}
+		p.node(start, expr)
+		return expr
 	}
-	return p.call()
+	return p.exponent()
+}
+
+// exponent binds tighter than unary operators on its left, so -2 ** 2 is
+// -4, and is right-associative, so 2 ** 3 ** 2 is 2 ** 9.
+func (p *Parser) exponent() Expr {
+	start := p.current
+	expr := p.postfix()
+	if p.match(STAR_STAR) {
+		op := p.previous()
+		right := p.unary()
+		expr = &Binary{expr, op, right}
+		p.node(start, expr)
+	}
+	return expr
+}
+
+func (p *Parser) postfix() Expr {
+	start := p.current
+	expr := p.call()
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
+		op := p.previous()
+		target, ok := expr.(*Variable)
+		if !ok {
+			panic(p.err(op, "Invalid increment target."))
+		}
+		expr = &Assign{target.name, op, &Literal{int64(1), op.line}, true}
+		p.node(start, expr)
+	}
+	return expr
 }
 
 func (p *Parser) call() Expr {
+	start := p.current
 	expr := p.primary()
 
 	for true {
 		if p.match(LEFT_PAREN) {
 			expr = p.finishCall(expr)
+			p.node(start, expr)
 		} else {
 			break
 		}
@@ -379,26 +540,55 @@ func (p *Parser) finishCall(callee Expr) Expr {
 	return &Call{callee, paren, arguments}
 }
 
-func (p *Parser) primary() Expr {
+func (p *Parser) primary() (expr Expr) {
+	start := p.current
+	defer func() {
+		p.node(start, expr)
+	}()
 	switch {
 	case p.match(FALSE):
-		return &Literal{false}
+		return &Literal{false, p.previous().line}
 	case p.match(TRUE):
-		return &Literal{true}
+		return &Literal{true, p.previous().line}
 	case p.match(NIL):
-		return &Literal{nil}
+		return &Literal{nil, p.previous().line}
 	case p.match(NUMBER, STRING):
-		return &Literal{p.previous().literal}
+		return &Literal{p.previous().literal, p.previous().line}
+	case p.match(INTERPOLATION):
+		return p.interpolation()
 	case p.match(IDENTIFIER):
 		return &Variable{p.previous()}
 	case p.match(LEFT_PAREN):
 		expr := p.expression()
 		p.consume(RIGHT_PAREN, "Expect ')' after expression")
-		return expr
+		return &Grouping{expr}
 	}
 	panic(p.err(p.peek(), "Expect expression."))
 }
 
+// interpolation lowers "a ${b} c" to "a " + str(b) + " c", where str is
+// a Unary with an INTERPOLATION operator that stringifies its operand like
+// print. The operators are not in the source and have empty lexemes.
+func (p *Parser) interpolation() Expr {
+	part := p.previous()
+	var expr Expr = &Literal{part.literal, part.line}
+	for {
+		synthetic := func(tType TokenType) Token {
+			return Token{tType: tType, line: part.line, column: part.column}
+		}
+		value := &Unary{synthetic(INTERPOLATION), p.expression()}
+		expr = &Binary{expr, synthetic(PLUS), value}
+		if !p.match(INTERPOLATION) {
+			p.consume(STRING, "Expect '}' after interpolated expression.")
+		}
+		part = p.previous()
+		expr = &Binary{expr, synthetic(PLUS), &Literal{part.literal, part.line}}
+		if part.tType == STRING {
+			return expr
+		}
+	}
+}
+
 // consume checks if the current token is of the expected type and
 // returns it or prints the error message and returns a ParseError.
 //
@@ -456,7 +646,7 @@ func (p *Parser) synchronize() {
 			return
 		}
 		switch p.peek().tType {
-		case CLASS, FOR, FUN, IF, PRINT, RETURN, VAR, WHILE:
+		case CLASS, CONST, FOR, FUN, IF, PRINT, RETURN, VAR, WHILE:
 			return
 		}
 		p.advance()
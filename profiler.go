@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profiler measures where a program spends its time. It counts the calls
+// and cumulative time of every function and the hits of every line. It
+// also looks at the call stack at every statement, call and return, which
+// makes it a sampling profiler that never misses a sample; the samples can
+// be exported for pprof.
+type Profiler struct {
+	file      string
+	functions map[functionKey]*FunctionProfile
+	lines     map[int]int
+	stack     []profileLocation
+	samples   map[string]*profileSample
+	last      time.Time
+}
+
+// functionKey identifies a function by its name and declaration line.
+type functionKey struct {
+	name string
+	line int
+}
+
+// FunctionProfile counts the calls of a function and the time spent in
+// them, including callees. Recursive calls are only timed once.
+type FunctionProfile struct {
+	name       string
+	line       int
+	calls      int
+	cumulative time.Duration
+	active     int
+	start      time.Time
+}
+
+type profileLocation struct {
+	function *FunctionProfile
+	line     int
+}
+
+// profileSample is the number of statements executed and the time spent
+// with a call stack, whose innermost location comes first.
+type profileSample struct {
+	stack []profileLocation
+	hits  int64
+	nanos int64
+}
+
+// NewProfiler starts profiling the program in file when i runs it. The
+// top-level code counts as a function named <script>.
+func NewProfiler(i *Interpreter, file string) *Profiler {
+	p := &Profiler{
+		file:      file,
+		functions: map[functionKey]*FunctionProfile{},
+		lines:     map[int]int{},
+		samples:   map[string]*profileSample{},
+		last:      time.Now(),
+	}
+	p.enter("<script>", 0)
+
+	onStmt, onCall, onReturn := i.onStmt, i.onCall, i.onReturn
+	i.onStmt = func(stmt Stmt) {
+		p.tick()
+		if line := stmtLine(stmt); line > 0 {
+			p.lines[line]++
+			p.stack[len(p.stack)-1].line = line
+			p.sample().hits++
+		}
+		if onStmt != nil {
+			onStmt(stmt)
+		}
+	}
+	i.onCall = func(fn LoxFunction, args []any) {
+		p.tick()
+		p.enter(fn.declaration.name.lexeme, fn.declaration.name.line)
+		if onCall != nil {
+			onCall(fn, args)
+		}
+	}
+	i.onReturn = func(fn LoxFunction, value any, unwinding bool) {
+		p.tick()
+		p.leave()
+		if onReturn != nil {
+			onReturn(fn, value, unwinding)
+		}
+	}
+	return p
+}
+
+func (p *Profiler) enter(name string, line int) {
+	key := functionKey{name, line}
+	function := p.functions[key]
+	if function == nil {
+		function = &FunctionProfile{name: name, line: line}
+		p.functions[key] = function
+	}
+	function.calls++
+	if function.active == 0 {
+		function.start = p.last
+	}
+	function.active++
+	p.stack = append(p.stack, profileLocation{function, line})
+}
+
+func (p *Profiler) leave() {
+	function := p.stack[len(p.stack)-1].function
+	p.stack = p.stack[:len(p.stack)-1]
+	function.active--
+	if function.active == 0 {
+		function.cumulative += p.last.Sub(function.start)
+	}
+}
+
+// tick charges the time since the last event to the current call stack.
+func (p *Profiler) tick() {
+	now := time.Now()
+	if len(p.stack) > 0 {
+		p.sample().nanos += int64(now.Sub(p.last))
+	}
+	p.last = now
+}
+
+func (p *Profiler) sample() *profileSample {
+	var key strings.Builder
+	for _, location := range p.stack {
+		fmt.Fprintf(&key, "%p:%v;", location.function, location.line)
+	}
+	sample := p.samples[key.String()]
+	if sample == nil {
+		stack := make([]profileLocation, len(p.stack))
+		for n, location := range p.stack {
+			stack[len(stack)-1-n] = location
+		}
+		sample = &profileSample{stack: stack}
+		p.samples[key.String()] = sample
+	}
+	return sample
+}
+
+// Stop ends the profile. Functions that are still running, like the
+// script after a runtime error, are timed up to now.
+func (p *Profiler) Stop() {
+	p.tick()
+	for len(p.stack) > 0 {
+		p.leave()
+	}
+}
+
+// Functions returns the profiled functions, most time consuming first.
+func (p *Profiler) Functions() []*FunctionProfile {
+	var functions []*FunctionProfile
+	for _, function := range p.functions {
+		functions = append(functions, function)
+	}
+	sort.Slice(functions, func(a, b int) bool {
+		if functions[a].cumulative != functions[b].cumulative {
+			return functions[a].cumulative > functions[b].cumulative
+		}
+		return functions[a].line < functions[b].line
+	})
+	return functions
+}
+
+// Report writes the calls and cumulative time of every function, followed
+// by the hits of every line.
+func (p *Profiler) Report(w io.Writer) {
+	fmt.Fprintf(w, "%10v %12v  %v\n", "calls", "cumulative", "function")
+	for _, function := range p.Functions() {
+		fmt.Fprintf(w, "%10v %12v  %v (line %v)\n", function.calls, function.cumulative, function.name, function.line)
+	}
+
+	var lines []int
+	for line := range p.lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	fmt.Fprintf(w, "\n%10v  %v\n", "hits", "line")
+	for _, line := range lines {
+		fmt.Fprintf(w, "%10v  %v\n", p.lines[line], line)
+	}
+}
+
+// protobuf encodes the few protocol buffer types the pprof format needs.
+type protobuf struct {
+	bytes.Buffer
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+func (b *protobuf) uint64Field(field int, x uint64) {
+	b.varint(uint64(field) << 3)
+	b.varint(x)
+}
+
+func (b *protobuf) int64Field(field int, x int64) {
+	b.uint64Field(field, uint64(x))
+}
+
+func (b *protobuf) bytesField(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+func (b *protobuf) message(field int, encode func(m *protobuf)) {
+	m := &protobuf{}
+	encode(m)
+	b.bytesField(field, m.Bytes())
+}
+
+// WritePprof writes the samples as a gzipped profile.proto message, which
+// "go tool pprof" reads. Every sample has the number of statements
+// executed and the time spent.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	index := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		if n, ok := index[s]; ok {
+			return n
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return index[s]
+	}
+
+	b := &protobuf{}
+	for _, valueType := range [][2]string{{"statements", "count"}, {"time", "nanoseconds"}} {
+		b.message(1, func(m *protobuf) {
+			m.int64Field(1, str(valueType[0]))
+			m.int64Field(2, str(valueType[1]))
+		})
+	}
+
+	var keys []string
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	functions := map[*FunctionProfile]uint64{}
+	locations := map[profileLocation]uint64{}
+	var functionOrder []*FunctionProfile
+	var locationOrder []profileLocation
+	for _, key := range keys {
+		sample := p.samples[key]
+		b.message(2, func(m *protobuf) {
+			for _, location := range sample.stack {
+				if functions[location.function] == 0 {
+					functions[location.function] = uint64(len(functions) + 1)
+					functionOrder = append(functionOrder, location.function)
+				}
+				if locations[location] == 0 {
+					locations[location] = uint64(len(locations) + 1)
+					locationOrder = append(locationOrder, location)
+				}
+				m.uint64Field(1, locations[location])
+			}
+			m.int64Field(2, sample.hits)
+			m.int64Field(2, sample.nanos)
+		})
+	}
+	for _, location := range locationOrder {
+		b.message(4, func(m *protobuf) {
+			m.uint64Field(1, locations[location])
+			m.message(4, func(line *protobuf) {
+				line.uint64Field(1, functions[location.function])
+				line.int64Field(2, int64(location.line))
+			})
+		})
+	}
+	for _, function := range functionOrder {
+		b.message(5, func(m *protobuf) {
+			m.uint64Field(1, functions[function])
+			// pprof drops names in angle brackets like C++ templates.
+			name := strings.Trim(function.name, "<>")
+			m.int64Field(2, str(name))
+			m.int64Field(3, str(name))
+			m.int64Field(4, str(p.file))
+			m.int64Field(5, int64(function.line))
+		})
+	}
+	b.message(11, func(m *protobuf) {
+		m.int64Field(1, str("time"))
+		m.int64Field(2, str("nanoseconds"))
+	})
+	b.int64Field(14, str("time"))
+	// The string table has to be complete before it is written.
+	for _, s := range table {
+		b.bytesField(6, []byte(s))
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return z.Close()
+}
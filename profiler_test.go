@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	source := `fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 2) + fib(n - 1);
+}
+print fib(5);
+`
+	i := NewInterpreter()
+	i.stdout = io.Discard
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	profiler := NewProfiler(i, "fib.lox")
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(stmts)
+	i.Interpret(stmts)
+	profiler.Stop()
+
+	functions := profiler.Functions()
+	if len(functions) != 2 {
+		t.Fatalf("got %v functions, want 2", len(functions))
+	}
+	script, fib := functions[0], functions[1]
+	if script.name != "<script>" || script.calls != 1 {
+		t.Errorf("got %+v, want the script first", script)
+	}
+	if fib.name != "fib" || fib.line != 1 || fib.calls != 15 {
+		t.Errorf("got %+v, want 15 calls of fib", fib)
+	}
+	if fib.cumulative <= 0 || fib.cumulative > script.cumulative || fib.active != 0 {
+		t.Errorf("fib took %v of the script's %v", fib.cumulative, script.cumulative)
+	}
+	// Line 2 has an if and a return.
+	wantLines := map[int]int{1: 1, 2: 23, 3: 7, 5: 1}
+	for line, hits := range wantLines {
+		if profiler.lines[line] != hits {
+			t.Errorf("line %v: got %v hits, want %v", line, profiler.lines[line], hits)
+		}
+	}
+
+	var report bytes.Buffer
+	profiler.Report(&report)
+	for _, want := range []string{"        15", "  fib (line 1)\n", "\n        23  2\n"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report doesn't contain %q:\n%v", want, report.String())
+		}
+	}
+
+	var pprof bytes.Buffer
+	if err := profiler.WritePprof(&pprof); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(&pprof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"fib.lox", "fib", "script", "nanoseconds"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("profile doesn't contain the string %q", want)
+		}
+	}
+}
+
+func TestProfilerAfterRuntimeError(t *testing.T) {
+	i := NewInterpreter()
+	i.stdout = io.Discard
+	profiler := NewProfiler(i, "fail.lox")
+	for _, source := range []string{"fun fail() { return 1 + nil; } fail();", "print 1;"} {
+		stmts := NewParser(NewScanner(source).scanTokens()).parse()
+		resolver := NewResolver(*i)
+		resolver.resolveStmts(stmts)
+		i.Interpret(stmts)
+	}
+	if len(profiler.stack) != 1 {
+		t.Errorf("got %v open frames after the error, want only the script", len(profiler.stack))
+	}
+	profiler.Stop()
+	for _, function := range profiler.Functions() {
+		if function.active != 0 {
+			t.Errorf("%v is still active", function.name)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errInterrupt is returned by readLine when the user cancels the input
+// with Ctrl-C.
+var errInterrupt = errors.New("interrupted")
+
+// lineReader reads the input of the REPL one line at a time.
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads lines without editing, e.g. from a pipe.
+type plainReader struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+func newPlainReader(in io.Reader, out io.Writer) *plainReader {
+	return &plainReader{bufio.NewScanner(in), out}
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.in.Scan() {
+		if err := r.in.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.in.Text(), nil
+}
+
+// REPL is the interactive prompt. The interpreter and the resolver are
+// kept across inputs, so everything declared earlier stays available.
+type REPL struct {
+	interpreter *Interpreter
+	resolver    Resolver
+	out         io.Writer
+	// session holds the inputs that ran without errors, for :save.
+	session []string
+}
+
+func NewREPL(i *Interpreter, out io.Writer) *REPL {
+	return &REPL{i, NewResolver(*i), out, nil}
+}
+
+// Run reads and runs inputs until the end of input. Inputs with unclosed
+// braces, parentheses or strings continue on the next line.
+func (r *REPL) Run(lines lineReader) error {
+	var input strings.Builder
+	for {
+		prompt := "> "
+		if input.Len() > 0 {
+			prompt = "... "
+		}
+		line, err := lines.readLine(prompt)
+		if err == errInterrupt {
+			input.Reset()
+			continue
+		}
+		if err == io.EOF {
+			fmt.Fprintln(r.out, "Bye!")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if input.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			r.command(strings.TrimSpace(line))
+			continue
+		}
+
+		input.WriteString(line + "\n")
+		if incomplete(input.String()) {
+			continue
+		}
+		r.run(input.String())
+		input.Reset()
+	}
+}
+
+// incomplete reports whether source needs more lines, because braces or
+// parentheses are open or a string is unterminated.
+func incomplete(source string) bool {
+	unterminated := false
+	hook := errorHook
+	errorHook = func(token *Token, line int, message string) {
+		unterminated = unterminated || message == "Unterminated string."
+	}
+	defer func() {
+		errorHook = hook
+		hadError = false
+	}()
+
+	depth := 0
+	for _, token := range NewScanner(source).scanTokens() {
+		switch token.tType {
+		case LEFT_BRACE, LEFT_PAREN:
+			depth++
+		case RIGHT_BRACE, RIGHT_PAREN:
+			depth--
+		}
+	}
+	return depth > 0 || unterminated
+}
+
+// run runs one input and reports whether it ran without errors. A lone
+// expression doesn't need a semicolon, and the values of expression
+// statements are printed unless they are nil.
+func (r *REPL) run(source string) bool {
+	defer func() {
+		hadError = false
+		hadRuntimeError = false
+	}()
+
+	var stmts []Stmt
+	if expr := parseExpression(source); expr != nil {
+		stmts = []Stmt{&Expression{expr}}
+		source = strings.TrimSpace(source) + ";\n"
+	} else {
+		stmts = parse(source)
+	}
+	if hadError {
+		return false
+	}
+	r.resolver.resolveStmts(stmts)
+	if hadError {
+		return false
+	}
+	r.execute(stmts)
+	if hadRuntimeError {
+		return false
+	}
+	r.session = append(r.session, source)
+	return true
+}
+
+// parseExpression returns source as an expression, or nil if it is
+// something else. Errors are not reported.
+func parseExpression(source string) (expr Expr) {
+	hook := errorHook
+	errorHook = func(token *Token, line int, message string) {}
+	defer func() {
+		errorHook = hook
+		hadError = false
+		if recover() != nil {
+			expr = nil
+		}
+	}()
+
+	parser := NewParser(NewScanner(source).scanTokens())
+	expr = parser.expression()
+	if !parser.isAtEnd() || hadError {
+		return nil
+	}
+	return expr
+}
+
+func (r *REPL) execute(stmts []Stmt) {
+	i := r.interpreter
+	defer func() {
+		if err := recover(); err != nil {
+			runtimeError(err.(error))
+		}
+	}()
+	defer i.popFrame()
+	i.pushFrame("<script>", i.environment)
+	for _, stmt := range stmts {
+		expr, ok := stmt.(*Expression)
+		if !ok {
+			i.Execute(stmt)
+			continue
+		}
+		if i.onStmt != nil {
+			i.onStmt(stmt)
+		}
+		if value := i.Evaluate(expr.expr); value != nil {
+			fmt.Fprintln(r.out, i.stringify(value))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	input := `fun makeCounter() {
+  var n = 0;
+  fun count() {
+    n = n + 1;
+    return n;
+  }
+  return count;
+}
+var counter = makeCounter();
+counter();
+counter()
+print "a" + "b";
+-"x";
+(1 +
+ 2) * 3
+"two
+lines"
+nil
+`
+	var out, errs bytes.Buffer
+	log.SetOutput(&errs)
+	defer log.SetOutput(os.Stderr)
+	repl := NewREPL(NewInterpreter(), &out)
+	repl.interpreter.stdout = &out
+	if err := repl.Run(newPlainReader(strings.NewReader(input), io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "1\n2\nab\n9\ntwo\nlines\nBye!\n"
+	if out.String() != want {
+		t.Errorf("got output\n%v\nwant\n%v", out.String(), want)
+	}
+	if !strings.Contains(errs.String(), "Operand must be a number.") {
+		t.Errorf("got errors %q", errs.String())
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := map[string]bool{
+		"print 1;":        false,
+		"fun f() {":       true,
+		"fun f() {\n}":    false,
+		"print (1 +":      true,
+		"print \"a":       true,
+		"print \"a\nb\";": false,
+		"}":               false,
+	}
+	for source, want := range tests {
+		if got := incomplete(source); got != want {
+			t.Errorf("incomplete(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	history := filepath.Join(t.TempDir(), "history")
+	e := &lineEditor{out: io.Discard, historyFile: history}
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{"print 1;\r", "print 1;"},
+		// Left arrow, insert, Ctrl-A, delete key, Ctrl-E, backspace.
+		{"ab\x1b[Dc\x01\x1b[3~\x05\x7fd\r", "cd"},
+		// Up arrow twice, down arrow once.
+		{"\x1b[A\x1b[A\x1b[B\r", "cd"},
+		// Ctrl-P, Ctrl-B, Ctrl-K, Ctrl-U.
+		{"\x10\x02\x0bx\x02\x15y\r", "yx"},
+		{"draft\x1b[A\x1b[B\r", "draft"},
+	}
+	for _, test := range tests {
+		e.in = bufio.NewReader(strings.NewReader(test.keys))
+		got, err := e.edit("> ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("keys %q: got %q, want %q", test.keys, got, test.want)
+		}
+		e.addHistory(got)
+	}
+
+	e.in = bufio.NewReader(strings.NewReader("abc\x03"))
+	if _, err := e.edit("> "); err != errInterrupt {
+		t.Errorf("Ctrl-C: got %v, want %v", err, errInterrupt)
+	}
+	e.in = bufio.NewReader(strings.NewReader("\x04"))
+	if _, err := e.edit("> "); err != io.EOF {
+		t.Errorf("Ctrl-D: got %v, want %v", err, io.EOF)
+	}
+
+	data, err := os.ReadFile(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "print 1;\ncd\nyx\ndraft\n"; string(data) != want {
+		t.Errorf("history file = %q, want %q", data, want)
+	}
+}
+
+func TestREPLCommands(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.lox")
+	if err := os.WriteFile(lib, []byte("fun twice(x) { return 2 * x; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	session := filepath.Join(dir, "session.lox")
+
+	var out bytes.Buffer
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	repl := NewREPL(NewInterpreter(), &out)
+	repl.interpreter.stdout = &out
+	run := func(input string) string {
+		out.Reset()
+		if err := repl.Run(newPlainReader(strings.NewReader(input), io.Discard)); err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSuffix(out.String(), "Bye!\n")
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{":load " + lib + "\nvar a = twice(2);\n:env\n", "a = 4\nassert = <native fn assert>\n" +
+			"assertEqual = <native fn assertEqual>\nclock = {}\nfloat = <native fn float>\nint = <native fn int>\n" +
+			"twice = <fn twice>\n"},
+		{":type a\n:type \"s\"\n:type twice\n:type nil\n:type a == 4\n:type clock\n",
+			"integer\nstring\nfunction\nnil\nboolean\nnative function\n"},
+		{":type var\n:type -\"a\"\n", "Expected an expression.\n"},
+		{":ast 1 + 2 * a\n:ast print a;\n", "(+@1 1@1 (*@1 2@1 a@1))\n(print@1 a@1)\n"},
+		{":tokens a;\n", "1:1 IDENTIFIER \"a\" nil\n1:2 SEMICOLON \";\" nil\n1:3 EOF \"\" nil\n"},
+		{"print -nil;\nvar b = 1;\na + b\n:save " + session + "\n", "5\n"},
+		{":reset\n:env\n", "assert = <native fn assert>\nassertEqual = <native fn assertEqual>\nclock = {}\n" +
+			"float = <native fn float>\nint = <native fn int>\n"},
+		{"const c = 1;\nc = 2;\nvar c = 3;\nfun c() {}\nprint c;\n", "1\n"},
+		{":frobnicate\n", "Unknown command ':frobnicate'. Try ':help'.\n"},
+	}
+	for _, test := range tests {
+		if got := run(test.input); got != test.want {
+			t.Errorf("input %q: got\n%v\nwant\n%v", test.input, got, test.want)
+		}
+	}
+
+	if got := run(":time 1 + 1\n"); !strings.HasPrefix(got, "2\n") || !strings.HasSuffix(got, "s\n") {
+		t.Errorf(":time printed %q", got)
+	}
+	data, err := os.ReadFile(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "fun twice(x) { return 2 * x; }\nvar a = twice(2);\nvar b = 1;\na + b;\n"
+	if string(data) != want {
+		t.Errorf("saved session %q, want %q", data, want)
+	}
+}
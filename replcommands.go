@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+const replHelp = `Commands:
+  :env            show the global variables
+  :type <expr>    show the type of an expression's value
+  :ast <code>     show the syntax tree of code
+  :tokens <code>  show the tokens of code
+  :load <file>    run a file in this session
+  :reset          forget everything declared in this session
+  :time <code>    run code and show how long it took
+  :save <file>    write the inputs that ran without errors to a file
+  :help           show this help`
+
+// command runs a REPL command, a line starting with a colon.
+func (r *REPL) command(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":env":
+		r.env()
+	case ":type":
+		if value, ok := r.evaluate(arg); ok {
+			fmt.Fprintln(r.out, typeName(value))
+		}
+	case ":ast":
+		r.ast(arg)
+	case ":tokens":
+		captureStatic(func() {
+			fmt.Fprint(r.out, DumpTokens(NewScanner(arg).scanTokens()))
+		})
+	case ":load":
+		data, err := ioutil.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+			return
+		}
+		r.run(string(data))
+	case ":reset":
+		stdout := r.interpreter.stdout
+		r.interpreter = NewInterpreter()
+		r.interpreter.stdout = stdout
+		r.resolver = NewResolver(*r.interpreter)
+		r.session = nil
+	case ":time":
+		start := time.Now()
+		r.run(arg + "\n")
+		fmt.Fprintln(r.out, time.Since(start))
+	case ":save":
+		err := ioutil.WriteFile(arg, []byte(strings.Join(r.session, "")), 0644)
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+		}
+	case ":help":
+		fmt.Fprintln(r.out, replHelp)
+	default:
+		fmt.Fprintf(r.out, "Unknown command '%v'. Try ':help'.\n", name)
+	}
+}
+
+// captureStatic runs fn and resets the error flags it leaves behind.
+func captureStatic(fn func()) {
+	defer func() {
+		hadError = false
+	}()
+	fn()
+}
+
+func (r *REPL) env() {
+	values := r.interpreter.globals.values
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "%v = %v\n", name, r.interpreter.stringify(values[name]))
+	}
+}
+
+// evaluate evaluates source as an expression in the session.
+func (r *REPL) evaluate(source string) (value any, ok bool) {
+	i := r.interpreter
+	defer func() {
+		hadError = false
+		if err := recover(); err != nil {
+			runtimeError(err.(error))
+			hadRuntimeError = false
+			value, ok = nil, false
+		}
+	}()
+	expr := parseExpression(source)
+	if expr == nil {
+		fmt.Fprintln(r.out, "Expected an expression.")
+		return nil, false
+	}
+	r.resolver.resolveExpr(expr)
+	if hadError {
+		return nil, false
+	}
+	return i.Evaluate(expr), true
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case *big.Int:
+		return "big integer"
+	case *Decimal:
+		return "decimal"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case LoxFunction:
+		return "function"
+	case *NativeFunction, LoxTime:
+		return "native function"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func (r *REPL) ast(source string) {
+	captureStatic(func() {
+		if expr := parseExpression(source); expr != nil {
+			fmt.Fprintln(r.out, expr.Accept(&sexprPrinter{}))
+			return
+		}
+		stmts := parse(source)
+		if !hadError {
+			fmt.Fprint(r.out, DumpSExpr(stmts))
+		}
+	})
+}
@@ -2,13 +2,27 @@ package main
 
 import "github.com/hadjian/golox/util"
 
+type FunctionType int
+
+const (
+	NONE FunctionType = iota
+	FUNCTION
+)
+
 type Resolver struct {
 	interpreter Interpreter
 	scopes      util.Stack
+	// constants holds the names of the constants declared in each of
+	// scopes.
+	constants       util.Stack
+	currentFunction FunctionType
+	// symbols is only set by tooling that needs declarations and
+	// references, e.g. the language server.
+	symbols *SymbolTable
 }
 
 func NewResolver(i Interpreter) Resolver {
-	return Resolver{i, util.Stack{}}
+	return Resolver{i, util.Stack{}, util.Stack{}, NONE, nil}
 }
 
 func (r *Resolver) resolveStmts(stmts []Stmt) {
@@ -26,11 +40,21 @@ func (r *Resolver) resolveExpr(expr Expr) {
 }
 
 func (r *Resolver) beginScope() {
-	r.scopes.Push(map[string]bool{})
+	r.pushScope(map[string]bool{}, map[string]bool{})
+	r.symbols.beginScope()
+}
+
+// pushScope opens a scope that already holds the names in scope, of which
+// those in constants can't be assigned.
+func (r *Resolver) pushScope(scope, constants map[string]bool) {
+	r.scopes.Push(scope)
+	r.constants.Push(constants)
 }
 
 func (r *Resolver) endScope() {
 	r.scopes.Pop()
+	r.constants.Pop()
+	r.symbols.endScope()
 }
 
 func (r *Resolver) VisitBlock(b *Block) {
@@ -41,10 +65,18 @@ func (r *Resolver) VisitBlock(b *Block) {
 
 func (r *Resolver) VisitVarStmt(v *Var) {
 	r.declare(v.name)
+	kind := VARIABLE_SYMBOL
+	if v.constant {
+		kind = CONSTANT_SYMBOL
+	}
+	r.symbols.declare(v.name, kind, 0, "")
 	if v.initializer != nil {
 		r.resolveExpr(v.initializer)
 	}
 	r.define(v.name)
+	if v.constant && !r.scopes.IsEmpty() {
+		r.constants.Peek().(map[string]bool)[v.name.lexeme] = true
+	}
 }
 
 func (r *Resolver) declare(name Token) {
@@ -52,6 +84,9 @@ func (r *Resolver) declare(name Token) {
 		return
 	}
 	scope := r.scopes.Peek().(map[string]bool)
+	if _, ok := scope[name.lexeme]; ok {
+		errToken(name, "Already a variable with this name in this scope.")
+	}
 	scope[name.lexeme] = false
 }
 
@@ -73,16 +108,31 @@ func (r *Resolver) VisitVariableExpr(expr *Variable) any {
 	return nil
 }
 
+// resolveLocal resolves name to the innermost scope that declares it, so
+// inner declarations shadow outer ones. Names not found are globals.
 func (r *Resolver) resolveLocal(expr Expr, name Token) {
 	for i := r.scopes.Size() - 1; i >= 0; i-- {
 		if _, ok := r.scopes.Get(i).(map[string]bool)[name.lexeme]; ok {
 			r.interpreter.Resolve(expr, r.scopes.Size()-1-i)
+			r.symbols.reference(name, r.scopes.Size()-1-i)
+			return
 		}
 	}
+	r.symbols.global(name)
 }
 
+// VisitAssign rejects assignments to local constants. Global constants
+// are late bound like all globals, so the interpreter checks them.
 func (r *Resolver) VisitAssign(expr *Assign) any {
 	r.resolveExpr(expr.value)
+	for i := r.scopes.Size() - 1; i >= 0; i-- {
+		if _, ok := r.scopes.Get(i).(map[string]bool)[expr.name.lexeme]; ok {
+			if r.constants.Get(i).(map[string]bool)[expr.name.lexeme] {
+				errToken(expr.name, "Can't assign to constant '"+expr.name.lexeme+"'.")
+			}
+			break
+		}
+	}
 	r.resolveLocal(expr, expr.name)
 	return nil
 }
@@ -90,14 +140,22 @@ func (r *Resolver) VisitAssign(expr *Assign) any {
 func (r *Resolver) VisitFunction(stmt *Function) {
 	r.declare(stmt.name)
 	r.define(stmt.name)
-	r.resolveFunction(*stmt)
+	r.symbols.declare(stmt.name, FUNCTION_SYMBOL, len(stmt.params), stmt.doc)
+	r.resolveFunction(*stmt, FUNCTION)
 }
 
-func (r *Resolver) resolveFunction(fn Function) {
+func (r *Resolver) resolveFunction(fn Function, fType FunctionType) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = fType
+	defer func() {
+		r.currentFunction = enclosingFunction
+	}()
+
 	r.beginScope()
 	for _, param := range fn.params {
 		r.declare(param)
 		r.define(param)
+		r.symbols.declare(param, PARAMETER_SYMBOL, 0, "")
 	}
 	r.resolveStmts(fn.body)
 	r.endScope()
@@ -120,11 +178,19 @@ func (r *Resolver) VisitPrint(stmt *Print) {
 }
 
 func (r *Resolver) VisitReturn(stmt *Return) {
+	if r.currentFunction == NONE {
+		errToken(stmt.keyword, "Can't return from top-level code.")
+	}
+	r.symbols.see(stmt.keyword)
 	if stmt.value != nil {
 		r.resolveExpr(stmt.value)
 	}
 }
 
+func (r *Resolver) VisitFor(stmt *For) {
+	r.resolveStmt(stmt.desugared)
+}
+
 func (r *Resolver) VisitWhile(stmt *While) {
 	r.resolveExpr(stmt.condition)
 	r.resolveStmt(stmt.body)
@@ -133,6 +199,7 @@ func (r *Resolver) VisitWhile(stmt *While) {
 func (r *Resolver) VisitBinary(expr *Binary) any {
 	r.resolveExpr(expr.Left)
 	r.resolveExpr(expr.Right)
+	r.symbols.see(expr.Operator)
 	return nil
 }
 
@@ -141,6 +208,7 @@ func (r *Resolver) VisitCallExpr(expr *Call) any {
 	for _, argument := range expr.arguments {
 		r.resolveExpr(argument)
 	}
+	r.symbols.see(expr.paren)
 	return nil
 }
 
@@ -153,13 +221,23 @@ func (r *Resolver) VisitLiteral(expr *Literal) any {
 	return nil
 }
 
+func (r *Resolver) VisitConditional(expr *Conditional) any {
+	r.resolveExpr(expr.condition)
+	r.resolveExpr(expr.thenBranch)
+	r.resolveExpr(expr.elseBranch)
+	r.symbols.see(expr.question)
+	return nil
+}
+
 func (r *Resolver) VisitLogical(expr *Logical) any {
 	r.resolveExpr(expr.left)
 	r.resolveExpr(expr.right)
+	r.symbols.see(expr.operator)
 	return nil
 }
 
 func (r *Resolver) VisitUnary(expr *Unary) any {
 	r.resolveExpr(expr.Right)
+	r.symbols.see(expr.Operator)
 	return nil
 }
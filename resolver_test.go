@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestResolverErrors(t *testing.T) {
+	tests := []struct {
+		code    string
+		wantErr bool
+	}{
+		{"return 1;", true},
+		{"{ return; }", true},
+		{"fun f() { return 1; }", false},
+		{"fun f() { fun g() { return; } return g; }", false},
+		{"{ var a = 1; var a = 2; }", true},
+		{"fun f(a, a) {}", true},
+		{"var a = 1; var a = 2;", false},
+		{"{ var a = 1; { var a = 2; } }", false},
+		{"{ const a = 1; a = 2; }", true},
+		{"{ const a = 1; fun f() { a += 1; } }", true},
+		{"{ const a = 1; { var a = 2; a = 3; } }", false},
+		{"fun f(a) { a = 1; } const a = 1;", false},
+		{"const a = 1; a = 2;", false},
+	}
+
+	for _, test := range tests {
+		hadError = false
+		stmts := NewParser(NewScanner(test.code).scanTokens()).parse()
+		resolver := NewResolver(*NewInterpreter())
+		resolver.resolveStmts(stmts)
+		if hadError != test.wantErr {
+			t.Errorf("resolve(%q): hadError = %v, want %v", test.code, hadError, test.wantErr)
+		}
+	}
+	hadError = false
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var keywords map[string]TokenType
@@ -11,6 +15,7 @@ func init() {
 	keywords = map[string]TokenType{
 		"and":    AND,
 		"class":  CLASS,
+		"const":  CONST,
 		"else":   ELSE,
 		"false":  FALSE,
 		"for":    FOR,
@@ -29,27 +34,76 @@ func init() {
 }
 
 type Scanner struct {
-	Source  []rune
-	tokens  []Token
-	start   int
-	current int
-	line    int
+	Source    []rune
+	tokens    []Token
+	start     int
+	current   int
+	line      int
+	lineStart int
+	column    int
+	// keepComments makes the scanner collect comments into comments
+	// instead of dropping them. They never appear in tokens.
+	keepComments bool
+	comments     []Token
+	// keepTrivia makes the scanner attach all text between tokens to
+	// them, so the source can be reproduced from the tokens.
+	keepTrivia bool
+	trivia     []Trivia
+	sawNewline bool
+	// doc collects the /// comments for the next token.
+	doc []string
+	// interpolations counts the open braces in each "${" expression the
+	// scanner is in, innermost last.
+	interpolations []int
+	// invalid maps the indexes in Source that hold U+FFFD in place of a
+	// byte that wasn't valid UTF-8 to that byte.
+	invalid map[int]byte
+	// bom is set if the source started with a byte order mark, which
+	// isn't part of Source.
+	bom bool
 }
 
+// NewScanner decodes source as UTF-8, skipping a byte order mark at the
+// start. Invalid bytes are reported when the scanner reaches them, but
+// lexemes and trivia keep them, so the source can still be reproduced.
 func NewScanner(source string) *Scanner {
-	return &Scanner{
-		Source: []rune(source),
-		line:   1,
+	s := &Scanner{line: 1}
+	if strings.HasPrefix(source, "\uFEFF") {
+		source = source[len("\uFEFF"):]
+		s.bom = true
 	}
+	s.Source = make([]rune, 0, len(source))
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRuneInString(source[i:])
+		if r == utf8.RuneError && size == 1 {
+			if s.invalid == nil {
+				s.invalid = map[int]byte{}
+			}
+			s.invalid[len(s.Source)] = source[i]
+		}
+		s.Source = append(s.Source, r)
+		i += size
+	}
+	return s
 }
 
 func (s *Scanner) scanTokens() []Token {
+	if s.keepTrivia && s.bom {
+		s.trivia = append(s.trivia, Trivia{WHITESPACE_TRIVIA, "\uFEFF"})
+	}
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.column = s.start - s.lineStart + 1
+		count := len(s.tokens)
 		s.scanToken()
+		if s.keepTrivia && len(s.tokens) == count {
+			s.addTrivia()
+		}
 	}
 
-	s.tokens = append(s.tokens, Token{EOF, "", nil, s.line})
+	s.start = s.current
+	s.column = s.current - s.lineStart + 1
+	s.addToken(EOF)
 	return s.tokens
 }
 
@@ -65,36 +119,73 @@ func (s *Scanner) scanToken() {
 	case ')':
 		s.addToken(RIGHT_PAREN)
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1]++
+		}
 		s.addToken(LEFT_BRACE)
 	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				s.interpolations = s.interpolations[:n-1]
+				s.string()
+				return
+			}
+			s.interpolations[n-1]--
+		}
 		s.addToken(RIGHT_BRACE)
 	case ',':
 		s.addToken(COMMA)
 	case '.':
 		s.addToken(DOT)
 	case '-':
-		s.addToken(MINUS)
+		s.addToken(s.matchTokens(MINUS, '-', MINUS_MINUS, '=', MINUS_EQUAL))
 	case '+':
-		s.addToken(PLUS)
+		s.addToken(s.matchTokens(PLUS, '+', PLUS_PLUS, '=', PLUS_EQUAL))
 	case ';':
 		s.addToken(SEMICOLON)
+	case ':':
+		s.addToken(COLON)
+	case '?':
+		s.addToken(s.matchToken('?', QUESTION_QUESTION, QUESTION))
 	case '*':
-		s.addToken(STAR)
+		s.addToken(s.matchTokens(STAR, '*', STAR_STAR, '=', STAR_EQUAL))
+	case '%':
+		s.addToken(s.matchToken('=', PERCENT_EQUAL, PERCENT))
+	case '&':
+		s.addToken(AMPERSAND)
+	case '|':
+		s.addToken(PIPE)
+	case '^':
+		s.addToken(CARET)
+	case '~':
+		s.addToken(s.matchToken('/', TILDE_SLASH, TILDE))
 	case '!':
 		s.addToken(s.matchToken('=', BANG_EQUAL, BANG))
 	case '=':
 		s.addToken(s.matchToken('=', EQUAL_EQUAL, EQUAL))
 	case '<':
-		s.addToken(s.matchToken('=', LESS_EQUAL, LESS))
+		s.addToken(s.matchTokens(LESS, '<', LESS_LESS, '=', LESS_EQUAL))
 	case '>':
-		s.addToken(s.matchToken('=', GREATER_EQUAL, GREATER))
+		s.addToken(s.matchTokens(GREATER, '>', GREATER_GREATER, '=', GREATER_EQUAL))
 	case '/':
 		if s.match('/') {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			text := s.text(s.start, s.current)
+			// A /// comment trailing code on its line doesn't document the
+			// next declaration.
+			ownLine := strings.TrimLeft(string(s.Source[s.lineStart:s.start]), " \t\r") == ""
+			if ownLine && strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////") {
+				s.doc = append(s.doc, strings.TrimPrefix(text[3:], " "))
+			} else {
+				s.doc = nil
+			}
+			s.addComment(s.line)
+		} else if s.match('*') {
+			s.blockComment()
 		} else {
-			s.addToken(SLASH)
+			s.addToken(s.matchToken('=', SLASH_EQUAL, SLASH))
 		}
 	case ' ':
 		fallthrough
@@ -102,7 +193,7 @@ func (s *Scanner) scanToken() {
 		fallthrough
 	case '\t':
 	case '\n':
-		s.line++
+		s.newline()
 	case '"':
 		s.string()
 	default:
@@ -110,14 +201,66 @@ func (s *Scanner) scanToken() {
 			s.number()
 		} else if s.isAlpha(r) {
 			s.identifier()
-		} else {
+		} else if _, ok := s.invalid[s.start]; !ok {
 			msg := fmt.Sprintf("Unexpected character %c", r)
 			errLine(s.line, msg)
 		}
 	}
 }
 
+// blockComment scans a /* */ comment, which may contain other block
+// comments.
+func (s *Scanner) blockComment() {
+	line := s.line
+	for depth := 1; depth > 0; {
+		if s.isAtEnd() {
+			errLine(s.line, "Unterminated block comment.")
+			break
+		}
+		switch r := s.advance(); {
+		case r == '\n':
+			s.newline()
+		case r == '/' && s.peek() == '*':
+			s.advance()
+			depth++
+		case r == '*' && s.peek() == '/':
+			s.advance()
+			depth--
+		}
+	}
+	s.doc = nil
+	s.addComment(line)
+}
+
+func (s *Scanner) addComment(line int) {
+	if s.keepComments {
+		text := s.text(s.start, s.current)
+		s.comments = append(s.comments, Token{COMMENT, text, nil, line, s.column, nil, ""})
+	}
+}
+
+// text returns the source between two indexes of Source, with the
+// original bytes in place of invalid UTF-8.
+func (s *Scanner) text(start, end int) string {
+	if s.invalid == nil {
+		return string(s.Source[start:end])
+	}
+	var b strings.Builder
+	for i, r := range s.Source[start:end] {
+		if invalid, ok := s.invalid[start+i]; ok {
+			b.WriteByte(invalid)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
 func (s *Scanner) advance() rune {
+	if b, ok := s.invalid[s.current]; ok {
+		column := s.current - s.lineStart + 1
+		errLine(s.line, fmt.Sprintf("Invalid UTF-8 byte 0x%02x at column %d.", b, column))
+	}
 	r := s.Source[s.current]
 	s.current++
 	return r
@@ -128,8 +271,49 @@ func (s *Scanner) addToken(t TokenType) {
 }
 
 func (s *Scanner) addTokenWithLiteral(t TokenType, literal any) {
-	text := s.Source[s.start:s.current]
-	s.tokens = append(s.tokens, Token{t, string(text), literal, s.line})
+	token := Token{t, s.text(s.start, s.current), literal, s.line, s.column, nil, strings.Join(s.doc, "\n")}
+	s.doc = nil
+	if s.keepTrivia {
+		token.trivia = &TokenTrivia{leading: s.trivia}
+		s.trivia = nil
+		s.sawNewline = false
+	}
+	s.tokens = append(s.tokens, token)
+}
+
+// addTrivia keeps the text of the current lexeme, which didn't make a
+// token. It trails the previous token until the end of its line.
+func (s *Scanner) addTrivia() {
+	text := s.text(s.start, s.current)
+	kind := SKIPPED_TRIVIA
+	switch s.Source[s.start] {
+	case ' ', '\r', '\t':
+		kind = WHITESPACE_TRIVIA
+	case '\n':
+		kind = NEWLINE_TRIVIA
+	case '/':
+		kind = COMMENT_TRIVIA
+	}
+
+	trivia := &s.trivia
+	if len(s.tokens) > 0 && !s.sawNewline {
+		trivia = &s.tokens[len(s.tokens)-1].trivia.trailing
+	}
+	if n := len(*trivia); kind == WHITESPACE_TRIVIA && n > 0 && (*trivia)[n-1].kind == kind {
+		(*trivia)[n-1].text += text
+	} else {
+		*trivia = append(*trivia, Trivia{kind, text})
+	}
+	if kind == NEWLINE_TRIVIA {
+		s.sawNewline = true
+	}
+}
+
+// newline must be called after consuming a '\n' to keep line and column
+// tracking in sync. Columns are 1-based and count runes.
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
 }
 
 func (s *Scanner) matchToken(expected rune, true, false TokenType) TokenType {
@@ -140,6 +324,15 @@ func (s *Scanner) matchToken(expected rune, true, false TokenType) TokenType {
 	return matchedToken
 }
 
+// matchTokens is like matchToken for operators with two possible second
+// characters, e.g. "+", "++" and "+=".
+func (s *Scanner) matchTokens(single TokenType, first rune, firstType TokenType, second rune, secondType TokenType) TokenType {
+	if s.match(first) {
+		return firstType
+	}
+	return s.matchToken(second, secondType, single)
+}
+
 func (s *Scanner) match(expected rune) bool {
 	if s.isAtEnd() {
 		return false
@@ -158,12 +351,26 @@ func (s *Scanner) peek() rune {
 	return s.Source[s.current]
 }
 
+// string scans a string literal or, after the "}" of an interpolation,
+// the rest of one. It stops at a "${", which starts an interpolation.
 func (s *Scanner) string() {
+	var value strings.Builder
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
+		r := s.advance()
+		switch {
+		case r == '\n':
+			s.newline()
+			value.WriteRune(r)
+		case r == '\\':
+			s.escape(&value)
+		case r == '$' && s.peek() == '{':
+			s.advance()
+			s.interpolations = append(s.interpolations, 0)
+			s.addTokenWithLiteral(INTERPOLATION, value.String())
+			return
+		default:
+			value.WriteRune(r)
 		}
-		s.advance()
 	}
 
 	if s.isAtEnd() {
@@ -172,9 +379,67 @@ func (s *Scanner) string() {
 	}
 
 	s.advance()
+	s.addTokenWithLiteral(STRING, value.String())
+}
 
-	value := string(s.Source[s.start+1 : s.current-1])
-	s.addTokenWithLiteral(STRING, value)
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'"':  '"',
+	'\\': '\\',
+	'$':  '$',
+}
+
+// escape reads the escape sequence after a backslash: one of \n \t \r \0
+// \" \\ \$, or a code point as \uXXXX or \u{X...}.
+func (s *Scanner) escape(value *strings.Builder) {
+	if s.isAtEnd() {
+		return
+	}
+	r := s.advance()
+	if escaped, ok := escapes[r]; ok {
+		value.WriteRune(escaped)
+		return
+	}
+	if r != 'u' {
+		errLine(s.line, "Invalid escape sequence '\\"+string(r)+"'.")
+		return
+	}
+
+	var digits []rune
+	escape := func() string { return "\\u" + string(digits) }
+	if s.peek() == '{' {
+		escape = func() string { return "\\u{" + string(digits) + "}" }
+		s.advance()
+		for s.peek() != '}' && s.peek() != '"' && !s.isAtEnd() {
+			digits = append(digits, s.advance())
+		}
+		if s.peek() != '}' || len(digits) == 0 || len(digits) > 6 {
+			errLine(s.line, "Invalid unicode escape '"+escape()+"'.")
+			return
+		}
+		s.advance()
+	} else {
+		for len(digits) < 4 && isHexDigit(s.peek()) {
+			digits = append(digits, s.advance())
+		}
+		if len(digits) < 4 {
+			errLine(s.line, "Invalid unicode escape '"+escape()+"'.")
+			return
+		}
+	}
+	code, err := strconv.ParseUint(string(digits), 16, 32)
+	if err != nil || code > unicode.MaxRune || code >= 0xD800 && code <= 0xDFFF {
+		errLine(s.line, "Invalid unicode escape '"+escape()+"'.")
+		return
+	}
+	value.WriteRune(rune(code))
+}
+
+func isHexDigit(r rune) bool {
+	return r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
 }
 
 func (s *Scanner) peekNext() rune {
@@ -188,24 +453,152 @@ func (s *Scanner) isDigit(r rune) bool {
 	return (r >= '0' && r <= '9')
 }
 
+// radixes are the prefixes of integer literals in other bases.
+var radixes = map[rune]int{'x': 16, 'X': 16, 'b': 2, 'B': 2, 'o': 8, 'O': 8}
+
+// number scans integers like 42, 1_000_000, 0xFF, 0b1010 and 0o17, floats
+// like 2.5 and 2.5e-3, big integers like 42n and decimals like 1.10d.
 func (s *Scanner) number() {
-	for s.isDigit(s.peek()) {
-		s.advance()
+	if base, ok := radixes[s.peek()]; ok && s.Source[s.start] == '0' {
+		s.radixNumber(base)
+		return
 	}
+	ok := s.digits(10)
+	fraction, exponent := false, false
 	if s.peek() == '.' && s.isDigit(s.peekNext()) {
 		s.advance()
-
-		for s.isDigit(s.peek()) {
+		fraction = true
+		ok = s.digits(10) && ok
+	}
+	if s.peek() == 'e' || s.peek() == 'E' {
+		s.advance()
+		exponent = true
+		if s.peek() == '+' || s.peek() == '-' {
 			s.advance()
 		}
+		if !s.isDigit(s.peek()) {
+			s.invalidNumber("Missing digits in exponent.")
+			return
+		}
+		ok = s.digits(10) && ok
+	}
+	suffix := ' '
+	if s.peek() == 'n' || s.peek() == 'd' {
+		suffix = s.advance()
+	}
+	if !ok {
+		s.invalidNumber("Misplaced '_' in number literal.")
+		return
+	}
+	if s.isAlphaNumeric(s.peek()) {
+		s.invalidNumber("")
+		return
+	}
+
+	text := strings.ReplaceAll(string(s.Source[s.start:s.current]), "_", "")
+	if suffix != ' ' {
+		text = text[:len(text)-1]
+	}
+	switch {
+	case suffix == 'n':
+		if fraction || exponent {
+			s.invalidNumber("A big integer literal can't have a fraction or exponent.")
+			return
+		}
+		value, _ := new(big.Int).SetString(text, 10)
+		s.addTokenWithLiteral(NUMBER, value)
+	case suffix == 'd':
+		value := parseDecimal(text)
+		if value == nil {
+			s.invalidNumber("Decimal literal is out of range.")
+			return
+		}
+		s.addTokenWithLiteral(NUMBER, value)
+	case fraction || exponent:
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			s.invalidNumber("Float literal is out of range.")
+			return
+		}
+		s.addTokenWithLiteral(NUMBER, value)
+	default:
+		s.integer(text, 10)
+	}
+}
+
+func (s *Scanner) radixNumber(base int) {
+	prefix := string(s.Source[s.start : s.current+1])
+	s.advance()
+	if !s.isDigitOf(base, s.peek()) {
+		s.invalidNumber("Missing digits after '" + prefix + "'.")
+		return
+	}
+	ok := s.digits(base)
+	isBig := s.match('n')
+	if !ok {
+		s.invalidNumber("Misplaced '_' in number literal.")
+		return
+	}
+	if s.isAlphaNumeric(s.peek()) {
+		s.invalidNumber("")
+		return
+	}
 
+	text := strings.ReplaceAll(string(s.Source[s.start+2:s.current]), "_", "")
+	if isBig {
+		value, _ := new(big.Int).SetString(strings.TrimSuffix(text, "n"), base)
+		s.addTokenWithLiteral(NUMBER, value)
+		return
 	}
+	s.integer(text, base)
+}
 
-	substring := string(s.Source[s.start:s.current])
-	value, _ := strconv.ParseFloat(substring, 64)
+func (s *Scanner) integer(text string, base int) {
+	value, err := strconv.ParseInt(text, base, 64)
+	if err != nil {
+		s.invalidNumber("Integer literal is too large.")
+		return
+	}
 	s.addTokenWithLiteral(NUMBER, value)
 }
 
+// digits scans digits of base separated by single underscores, and
+// reports false if an underscore isn't between two digits.
+func (s *Scanner) digits(base int) bool {
+	ok := true
+	for s.isDigitOf(base, s.peek()) || s.peek() == '_' {
+		if s.advance() == '_' && !s.isDigitOf(base, s.peek()) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+func (s *Scanner) isDigitOf(base int, r rune) bool {
+	switch base {
+	case 2:
+		return r == '0' || r == '1'
+	case 8:
+		return r >= '0' && r <= '7'
+	case 16:
+		return isHexDigit(r)
+	}
+	return s.isDigit(r)
+}
+
+// invalidNumber reports a malformed number literal, skipping the rest of
+// it without adding a token. Without a message, it reports the
+// whole literal.
+func (s *Scanner) invalidNumber(message string) {
+	for s.isAlphaNumeric(s.peek()) || s.peek() == '_' {
+		s.advance()
+	}
+	if message == "" {
+		message = "Invalid number literal '" + string(s.Source[s.start:s.current]) + "'."
+	}
+	errLine(s.line, message)
+}
+
 func (s *Scanner) identifier() {
 	for s.isAlphaNumeric(s.peek()) {
 		s.advance()
@@ -218,12 +611,14 @@ func (s *Scanner) identifier() {
 	s.addToken(tokenType)
 }
 
+// isAlpha accepts the letters of any script, so identifiers like größe
+// and 名前 work. Number literals still only use ASCII digits.
 func (s *Scanner) isAlpha(r rune) bool {
-	return (r >= 'a' && r <= 'z') ||
-		(r >= 'A' && r <= 'Z') ||
-		(r == '_')
+	return r == '_' || unicode.IsLetter(r)
 }
 
+// isAlphaNumeric also accepts decimal digits and combining marks of any
+// script after the first rune of an identifier.
 func (s *Scanner) isAlphaNumeric(r rune) bool {
-	return s.isAlpha(r) || s.isDigit(r)
+	return s.isAlpha(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -52,3 +53,228 @@ func TestScanner(t *testing.T) {
 
 	}
 }
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"\t\r\0\"\\\$"`, "\t\r\x00\"\\$"},
+		{`"é\u{1F600}"`, "é😀"},
+	}
+	for _, test := range tests {
+		tokens := NewScanner(test.code).scanTokens()
+		if got := tokens[0].literal; got != test.want {
+			t.Errorf("%v: got %q, want %q", test.code, got, test.want)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	code := `"a${b + "${c}"}d"`
+	expected := []TokenType{
+		INTERPOLATION,
+		IDENTIFIER,
+		PLUS,
+		INTERPOLATION,
+		IDENTIFIER,
+		STRING,
+		STRING,
+		EOF,
+	}
+	tokens := NewScanner(code).scanTokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("got %v tokens, want %v", len(tokens), len(expected))
+	}
+	for i, token := range tokens {
+		if token.tType != expected[i] {
+			t.Errorf("tokens[%d] = %s, expected %s", i, token.tType, expected[i])
+		}
+	}
+	if tokens[0].literal != "a" || tokens[6].literal != "d" {
+		t.Errorf("got parts %q and %q, want \"a\" and \"d\"", tokens[0].literal, tokens[6].literal)
+	}
+}
+
+func TestStringEscapeErrors(t *testing.T) {
+	tests := map[string]string{
+		`"\q"`:         `Invalid escape sequence '\q'.`,
+		`"\u12"`:       `Invalid unicode escape '\u12'.`,
+		`"\u{}"`:       `Invalid unicode escape '\u{}'.`,
+		`"\u{110000}"`: `Invalid unicode escape '\u{110000}'.`,
+		`"\uD800"`:     `Invalid unicode escape '\uD800'.`,
+	}
+	defer func() {
+		errorHook = nil
+		hadError = false
+	}()
+	for code, want := range tests {
+		var got string
+		errorHook = func(token *Token, line int, message string) {
+			got = message
+		}
+		NewScanner(code).scanTokens()
+		if got != want {
+			t.Errorf("%v: got error %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"0xFF", "255"},
+		{"0Xab", "171"},
+		{"0b1010", "10"},
+		{"0o17", "15"},
+		{"1_000_000", "1000000"},
+		{"1e9", "1000000000.0"},
+		{"2.5e-3", "0.0025"},
+		{"1E+2", "100.0"},
+		{"0xFFFF_FFFF_FFFF_FFFFn", "18446744073709551615"},
+		{"1.5e3d", "1500"},
+	}
+	i := Interpreter{}
+	for _, test := range tests {
+		tokens := NewScanner(test.code).scanTokens()
+		if tokens[0].tType != NUMBER || len(tokens) != 2 {
+			t.Errorf("%v: got tokens %v", test.code, tokens)
+			continue
+		}
+		if got := i.stringify(tokens[0].literal); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.code, got, test.want)
+		}
+	}
+}
+
+func TestNumberLiteralErrors(t *testing.T) {
+	tests := map[string]string{
+		"0x":                      "Missing digits after '0x'.",
+		"0b":                      "Missing digits after '0b'.",
+		"1e":                      "Missing digits in exponent.",
+		"1e+":                     "Missing digits in exponent.",
+		"1__000":                  "Misplaced '_' in number literal.",
+		"1_":                      "Misplaced '_' in number literal.",
+		"0x_1":                    "Missing digits after '0x'.",
+		"0b102":                   "Invalid number literal '0b102'.",
+		"0o8":                     "Missing digits after '0o'.",
+		"12abc":                   "Invalid number literal '12abc'.",
+		"1.5n":                    "A big integer literal can't have a fraction or exponent.",
+		"1e400":                   "Float literal is out of range.",
+		"1e99999999999999999999d": "Decimal literal is out of range.",
+		"0x1_0000_0000_0000_0000": "Integer literal is too large.",
+	}
+	defer func() {
+		errorHook = nil
+		hadError = false
+	}()
+	for code, want := range tests {
+		var got string
+		errorHook = func(token *Token, line int, message string) {
+			got = message
+		}
+		tokens := NewScanner(code).scanTokens()
+		if got != want {
+			t.Errorf("%v: got error %q, want %q", code, got, want)
+		}
+		if len(tokens) != 1 {
+			t.Errorf("%v: got tokens %v, want only EOF", code, tokens)
+		}
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	s := NewScanner("/* one\n /* two */\n */ a /**/ b\n/* c */ c")
+	s.keepComments = true
+	tokens := s.scanTokens()
+	want := []struct {
+		lexeme string
+		line   int
+	}{{"a", 3}, {"b", 3}, {"c", 4}, {"", 4}}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %v tokens, want %v", len(tokens), len(want))
+	}
+	for i, token := range tokens {
+		if token.lexeme != want[i].lexeme || token.line != want[i].line {
+			t.Errorf("tokens[%d] = %q on line %v, want %q on line %v",
+				i, token.lexeme, token.line, want[i].lexeme, want[i].line)
+		}
+	}
+	if len(s.comments) != 3 || s.comments[0].line != 1 || s.comments[2].lexeme != "/* c */" {
+		t.Errorf("got comments %v", s.comments)
+	}
+
+	var got string
+	errorHook = func(token *Token, line int, message string) {
+		got = fmt.Sprintf("[line %v] %v", line, message)
+	}
+	defer func() {
+		errorHook = nil
+		hadError = false
+	}()
+	NewScanner("a /* /* */\n").scanTokens()
+	if want := "[line 2] Unterminated block comment."; got != want {
+		t.Errorf("got error %q, want %q", got, want)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	source := "/// Adds two numbers.\n///\n///   Indented.\nfun add(a, b) {}\n" +
+		"/// Dropped by the next comment.\n// plain\nfun f() {}\n//// not a doc comment\nfun g() {}\n" +
+		"var a = 1; /// trailing, not a doc comment\nfun h() {}\n"
+	stmts := NewParser(NewScanner(source).scanTokens()).parse()
+	want := map[string]string{"add": "Adds two numbers.\n\n  Indented.", "f": "", "g": "", "h": ""}
+	for _, stmt := range stmts {
+		if function, ok := stmt.(*Function); ok && function.doc != want[function.name.lexeme] {
+			t.Errorf("doc of %v = %q, want %q", function.name.lexeme, function.doc, want[function.name.lexeme])
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	tokens := NewScanner("\uFEFFvar größe = 1;\nvar 名前 = größe; x١ e\u0301").scanTokens()
+	want := []struct {
+		lexeme       string
+		line, column int
+	}{
+		{"var", 1, 1}, {"größe", 1, 5}, {"=", 1, 11}, {"1", 1, 13}, {";", 1, 14},
+		{"var", 2, 1}, {"名前", 2, 5}, {"=", 2, 8}, {"größe", 2, 10}, {";", 2, 15},
+		{"x١", 2, 17}, {"e\u0301", 2, 20}, {"", 2, 22},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %v tokens, want %v", len(tokens), len(want))
+	}
+	for i, token := range tokens {
+		if token.lexeme != want[i].lexeme || token.line != want[i].line || token.column != want[i].column {
+			t.Errorf("tokens[%d] = %q at %v:%v, want %q at %v:%v", i, token.lexeme,
+				token.line, token.column, want[i].lexeme, want[i].line, want[i].column)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	var got []string
+	errorHook = func(token *Token, line int, message string) {
+		got = append(got, fmt.Sprintf("[line %v] %v", line, message))
+	}
+	defer func() {
+		errorHook = nil
+		hadError = false
+	}()
+	tokens := NewScanner("a \xff b\n\"ü\xc3\" // \xe2\x82\n").scanTokens()
+	want := []string{
+		"[line 1] Invalid UTF-8 byte 0xff at column 3.",
+		"[line 2] Invalid UTF-8 byte 0xc3 at column 3.",
+		"[line 2] Invalid UTF-8 byte 0xe2 at column 9.",
+		"[line 2] Invalid UTF-8 byte 0x82 at column 10.",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got errors %q, want %q", got, want)
+	}
+	if len(tokens) != 4 {
+		t.Errorf("got %v tokens, want a, b, a string and EOF", len(tokens))
+	}
+}
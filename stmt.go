@@ -4,6 +4,7 @@ type StmtVisitor interface {
 	VisitBlock(b *Block)
 	VisitExpressionStmt(e *Expression)
 	VisitFunction(f *Function)
+	VisitFor(f *For)
 	VisitWhile(w *While)
 	VisitIf(f *If)
 	VisitPrint(p *Print)
@@ -35,13 +36,57 @@ type Function struct {
 	name   Token
 	params []Token
 	body   []Stmt
+	// doc is the text of the /// comments before the declaration.
+	doc string
 }
 
 func (f *Function) Accept(v StmtVisitor) {
 	v.VisitFunction(f)
 }
 
+// For keeps the clauses of a for loop for tooling. It is resolved as the
+// while loop it desugars to, and the interpreter runs its clauses in the
+// same environments.
+type For struct {
+	keyword     Token
+	initializer Stmt
+	condition   Expr
+	increment   Expr
+	body        Stmt
+	desugared   Stmt
+}
+
+// NewFor desugars the clauses of a for loop into a while loop.
+func NewFor(keyword Token, initializer Stmt, condition, increment Expr, body Stmt) *For {
+	desugared := body
+
+	if increment != nil {
+		desugared = &Block{
+			[]Stmt{desugared, &Expression{increment}},
+		}
+	}
+
+	loop := condition
+	if loop == nil {
+		loop = &Literal{true, keyword.line}
+	}
+	desugared = &While{keyword, loop, desugared}
+
+	if initializer != nil {
+		desugared = &Block{
+			[]Stmt{initializer, desugared},
+		}
+	}
+
+	return &For{keyword, initializer, condition, increment, body, desugared}
+}
+
+func (f *For) Accept(v StmtVisitor) {
+	v.VisitFor(f)
+}
+
 type While struct {
+	keyword   Token
 	condition Expr
 	body      Stmt
 }
@@ -51,6 +96,7 @@ func (w *While) Accept(v StmtVisitor) {
 }
 
 type If struct {
+	keyword    Token
 	condition  Expr
 	thenBranch Stmt
 	elseBranch Stmt
@@ -61,7 +107,8 @@ func (f *If) Accept(v StmtVisitor) {
 }
 
 type Print struct {
-	expr Expr
+	keyword Token
+	expr    Expr
 }
 
 func (p *Print) Accept(v StmtVisitor) {
@@ -77,11 +124,40 @@ func (r *Return) Accept(v StmtVisitor) {
 	v.VisitReturn(r)
 }
 
+// Var declares a variable, or a constant if it is declared with const.
+// Constants always have an initializer.
 type Var struct {
 	name        Token
 	initializer Expr
+	constant    bool
 }
 
 func (vr *Var) Accept(v StmtVisitor) {
 	v.VisitVarStmt(vr)
 }
+
+// stmtLine returns the line a statement starts on, or 0 if it has no
+// token to tell, like a block or an expression statement of a literal.
+func stmtLine(stmt Stmt) int {
+	switch stmt := stmt.(type) {
+	case *Block:
+		return 0
+	case *Expression:
+		return exprLine(stmt.expr)
+	case *Function:
+		return stmt.name.line
+	case *For:
+		return stmt.keyword.line
+	case *While:
+		return stmt.keyword.line
+	case *If:
+		return stmt.keyword.line
+	case *Print:
+		return stmt.keyword.line
+	case *Return:
+		return stmt.keyword.line
+	case *Var:
+		return stmt.name.line
+	}
+	return 0
+}
@@ -0,0 +1,233 @@
+package main
+
+type SymbolKind int
+
+const (
+	VARIABLE_SYMBOL SymbolKind = iota
+	PARAMETER_SYMBOL
+	FUNCTION_SYMBOL
+	CONSTANT_SYMBOL
+)
+
+// Symbol is a name declared by a var, const, fun or parameter.
+type Symbol struct {
+	name  Token
+	kind  SymbolKind
+	arity int
+	scope *Scope
+	// doc is the doc comment of a function.
+	doc string
+}
+
+// Scope mirrors one of the resolver's scopes. first and last are the
+// outermost tokens the resolver saw while the scope was open, which
+// approximates the source range the scope covers.
+type Scope struct {
+	enclosing *Scope
+	children  []*Scope
+	symbols   []*Symbol
+	first     *Token
+	last      *Token
+}
+
+// Reference is a use of a name together with the symbol it resolved to.
+type Reference struct {
+	name   Token
+	symbol *Symbol
+}
+
+// SymbolTable collects declarations and references while the Resolver
+// walks a program. It is only populated for tooling, like the language
+// server; all methods are no-ops on a nil table.
+type SymbolTable struct {
+	globals    *Scope
+	current    *Scope
+	references []Reference
+	unresolved []Token
+}
+
+func NewSymbolTable() *SymbolTable {
+	globals := &Scope{}
+	return &SymbolTable{globals: globals, current: globals}
+}
+
+func (t *SymbolTable) beginScope() {
+	if t == nil {
+		return
+	}
+	scope := &Scope{enclosing: t.current}
+	t.current.children = append(t.current.children, scope)
+	t.current = scope
+}
+
+func (t *SymbolTable) endScope() {
+	if t == nil {
+		return
+	}
+	t.current = t.current.enclosing
+}
+
+func (t *SymbolTable) declare(name Token, kind SymbolKind, arity int, doc string) {
+	if t == nil {
+		return
+	}
+	t.see(name)
+	symbol := &Symbol{name, kind, arity, t.current, doc}
+	t.current.symbols = append(t.current.symbols, symbol)
+}
+
+// reference records a use of name that the resolver found distance
+// scopes out. Globals are resolved by name in finish, because they are
+// late bound.
+func (t *SymbolTable) reference(name Token, distance int) {
+	if t == nil {
+		return
+	}
+	t.see(name)
+	scope := t.current
+	for i := 0; i < distance; i++ {
+		scope = scope.enclosing
+	}
+	if symbol := scope.lookup(name.lexeme); symbol != nil {
+		t.references = append(t.references, Reference{name, symbol})
+	}
+}
+
+func (t *SymbolTable) global(name Token) {
+	if t == nil {
+		return
+	}
+	t.see(name)
+	t.unresolved = append(t.unresolved, name)
+}
+
+// see widens the range of the current scope and its enclosing scopes to
+// include token.
+func (t *SymbolTable) see(token Token) {
+	if t == nil {
+		return
+	}
+	for scope := t.current; scope != nil; scope = scope.enclosing {
+		if scope.first == nil || before(token, *scope.first) {
+			scope.first = &token
+		}
+		if scope.last == nil || before(*scope.last, token) {
+			scope.last = &token
+		}
+	}
+}
+
+// finish resolves the remaining names against the global scope. It must
+// be called once the whole program has been resolved.
+func (t *SymbolTable) finish() {
+	if t == nil {
+		return
+	}
+	for _, name := range t.unresolved {
+		if symbol := t.globals.lookup(name.lexeme); symbol != nil {
+			t.references = append(t.references, Reference{name, symbol})
+		}
+	}
+	t.unresolved = nil
+}
+
+// SymbolAt returns the symbol declared or referenced at the 1-based line
+// and column, or nil.
+func (t *SymbolTable) SymbolAt(line, column int) *Symbol {
+	for _, ref := range t.references {
+		if covers(ref.name, line, column) {
+			return ref.symbol
+		}
+	}
+	var found *Symbol
+	t.globals.walk(func(s *Symbol) {
+		if covers(s.name, line, column) {
+			found = s
+		}
+	})
+	return found
+}
+
+// Symbols returns every symbol in declaration order of their scopes.
+func (t *SymbolTable) Symbols() []*Symbol {
+	var symbols []*Symbol
+	t.globals.walk(func(s *Symbol) {
+		symbols = append(symbols, s)
+	})
+	return symbols
+}
+
+// Visible returns the symbols that can be referenced at the 1-based line
+// and column, innermost first. Locals must be declared before the
+// position, globals are visible everywhere.
+func (t *SymbolTable) Visible(line, column int) []*Symbol {
+	scope := t.globals.innermost(line, column)
+	seen := map[string]bool{}
+	var visible []*Symbol
+	for ; scope != nil; scope = scope.enclosing {
+		for i := len(scope.symbols) - 1; i >= 0; i-- {
+			symbol := scope.symbols[i]
+			if seen[symbol.name.lexeme] {
+				continue
+			}
+			declared := symbol.name.line < line ||
+				(symbol.name.line == line && symbol.name.column < column)
+			if scope != t.globals && !declared {
+				continue
+			}
+			seen[symbol.name.lexeme] = true
+			visible = append(visible, symbol)
+		}
+	}
+	return visible
+}
+
+func (s *Scope) lookup(name string) *Symbol {
+	for i := len(s.symbols) - 1; i >= 0; i-- {
+		if s.symbols[i].name.lexeme == name {
+			return s.symbols[i]
+		}
+	}
+	return nil
+}
+
+func (s *Scope) walk(fn func(*Symbol)) {
+	for _, symbol := range s.symbols {
+		fn(symbol)
+	}
+	for _, child := range s.children {
+		child.walk(fn)
+	}
+}
+
+func (s *Scope) contains(line, column int) bool {
+	if s.first == nil {
+		return false
+	}
+	end := *s.last
+	end.column += len([]rune(end.lexeme))
+	pos := Token{line: line, column: column}
+	return !before(pos, *s.first) && !before(end, pos)
+}
+
+func (s *Scope) innermost(line, column int) *Scope {
+	for _, child := range s.children {
+		if child.contains(line, column) {
+			return child.innermost(line, column)
+		}
+	}
+	return s
+}
+
+// before reports whether a starts before b.
+func before(a, b Token) bool {
+	return a.line < b.line || (a.line == b.line && a.column < b.column)
+}
+
+// covers reports whether the 1-based position lies on token, including
+// the position right after its last rune.
+func covers(token Token, line, column int) bool {
+	length := len([]rune(token.lexeme))
+	return token.line == line &&
+		column >= token.column && column <= token.column+length
+}
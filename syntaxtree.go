@@ -0,0 +1,137 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// span is the range of tokens a node was parsed from.
+type span struct {
+	kind  string
+	start int
+	end   int
+}
+
+// SyntaxNode is a node of the lossless syntax tree. Its children are
+// *SyntaxNode or Token, in source order, and together they cover every
+// token of the node with its trivia.
+type SyntaxNode struct {
+	kind     string
+	children []any
+}
+
+// node records the tokens from start up to the current one as a syntax
+// node for n, which is an AST node or the name of the kind.
+func (p *Parser) node(start int, n any) {
+	if !p.syntax || n == nil || reflect.ValueOf(n).IsZero() {
+		return
+	}
+	kind, ok := n.(string)
+	if !ok {
+		kind = reflect.TypeOf(n).Elem().Name()
+	}
+	p.spans = append(p.spans, span{kind, start, p.current})
+}
+
+// ParseSyntaxTree parses source into a lossless syntax tree, from which
+// the source can be reproduced byte for byte, even if it has errors. The
+// AST is returned as well, so tools can work with both.
+func ParseSyntaxTree(source string) (*SyntaxNode, []Stmt) {
+	scanner := NewScanner(source)
+	scanner.keepTrivia = true
+	tokens := scanner.scanTokens()
+	parser := NewParser(tokens)
+	parser.syntax = true
+	stmts := parser.parse()
+	return buildSyntaxTree(tokens, parser.spans), stmts
+}
+
+// buildSyntaxTree nests the spans, which the parser records when their
+// node is complete, i.e. children before parents.
+func buildSyntaxTree(tokens []Token, spans []span) *SyntaxNode {
+	order := make([]int, len(spans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := spans[order[a]], spans[order[b]]
+		if sa.start != sb.start {
+			return sa.start < sb.start
+		}
+		if sa.end != sb.end {
+			return sa.end > sb.end
+		}
+		return order[a] > order[b]
+	})
+
+	sorted := make([]span, 0, len(spans))
+	for _, i := range order {
+		if spans[i].end > spans[i].start {
+			sorted = append(sorted, spans[i])
+		}
+	}
+	root, _ := build(span{"Program", 0, len(tokens)}, sorted, tokens)
+	return root
+}
+
+// build creates the node for s from the sorted spans, consuming the
+// spans nested in s, and returns the remaining ones.
+func build(s span, spans []span, tokens []Token) (*SyntaxNode, []span) {
+	node := &SyntaxNode{kind: s.kind}
+	for i := s.start; i < s.end; {
+		for len(spans) > 0 && spans[0].start < i {
+			spans = spans[1:]
+		}
+		if len(spans) > 0 && spans[0].start == i && spans[0].end <= s.end {
+			var child *SyntaxNode
+			end := spans[0].end
+			child, spans = build(spans[0], spans[1:], tokens)
+			node.children = append(node.children, child)
+			i = end
+			continue
+		}
+		node.children = append(node.children, tokens[i])
+		i++
+	}
+	return node, spans
+}
+
+// Text returns the source the node was parsed from, including trivia.
+func (n *SyntaxNode) Text() string {
+	var b strings.Builder
+	for _, token := range n.Tokens() {
+		b.WriteString(token.FullText())
+	}
+	return b.String()
+}
+
+// Tokens returns the tokens of the node in source order.
+func (n *SyntaxNode) Tokens() []Token {
+	var tokens []Token
+	for _, child := range n.children {
+		switch child := child.(type) {
+		case *SyntaxNode:
+			tokens = append(tokens, child.Tokens()...)
+		case Token:
+			tokens = append(tokens, child)
+		}
+	}
+	return tokens
+}
+
+// String prints the tree as nested lists of kinds and lexemes.
+func (n *SyntaxNode) String() string {
+	var parts []string
+	for _, child := range n.children {
+		switch child := child.(type) {
+		case *SyntaxNode:
+			parts = append(parts, child.String())
+		case Token:
+			if child.tType != EOF {
+				parts = append(parts, child.lexeme)
+			}
+		}
+	}
+	return "(" + n.kind + " " + strings.Join(parts, " ") + ")"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSyntaxTreeIsLossless(t *testing.T) {
+	sources := []string{
+		"",
+		"\n\n",
+		"// only a comment",
+		"var a = 1;   // trailing\n\n\n// leading\nprint a;\n",
+		"fun add(x, y) {\r\n\treturn x + y;\r\n}\r\n",
+		"for (var i = 0; i < 3; i = i + 1) { print (i * 2); }",
+		"var = ;\nprint 1 +;\n",
+		"print \"unterminated",
+		"var x = 1 @ 2;",
+		"if (a) b(); else { c = !d or e and -f; }   ",
+		"/* a /* nested */\n block */ var a = /* inline */ 1;\n/// doc\nfun f() {}",
+		"print 1; /* unterminated",
+		"\uFEFFprint 1;\n",
+		"a\xffb",
+		"print \"\xc3\"; // \xe2\x82\n",
+	}
+
+	defer func() {
+		hadError = false
+	}()
+	for _, source := range sources {
+		tree, _ := ParseSyntaxTree(source)
+		if got := tree.Text(); got != source {
+			t.Errorf("Text() = %q, want %q", got, source)
+		}
+	}
+}
+
+func TestSyntaxTree(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			"var a = 1 + 2 * 3;",
+			"(Program (Var var a = (Binary (Literal 1) + (Binary (Literal 2) * (Literal 3))) ;))",
+		},
+		{
+			"fun f(x) { return f(x); }",
+			"(Program (Function fun f ( x ) { (Return return (Call (Variable f) ( (Variable x) )) ;) }))",
+		},
+		{
+			"for (;;) a = (b);",
+			"(Program (For for ( ; ; ) (Expression (Assign (Variable a) = (Grouping ( (Variable b) ))) ;)))",
+		},
+		{
+			"var = 1; print 2;",
+			"(Program (Error var = 1 ;) (Print print (Literal 2) ;))",
+		},
+	}
+
+	defer func() {
+		hadError = false
+	}()
+	for _, test := range tests {
+		tree, _ := ParseSyntaxTree(test.source)
+		if got := tree.String(); got != test.want {
+			t.Errorf("ParseSyntaxTree(%q) =\n%v\nwant\n%v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	s := NewScanner("a // one\n\n  b")
+	s.keepTrivia = true
+	tokens := s.scanTokens()
+
+	trailing := tokens[0].trivia.trailing
+	if len(trailing) != 3 || trailing[1].kind != COMMENT_TRIVIA || trailing[2].kind != NEWLINE_TRIVIA {
+		t.Errorf("trailing trivia of 'a' = %v", trailing)
+	}
+	leading := tokens[1].trivia.leading
+	if len(leading) != 2 || leading[0].kind != NEWLINE_TRIVIA || leading[1].text != "  " {
+		t.Errorf("leading trivia of 'b' = %v", leading)
+	}
+}
@@ -2,7 +2,10 @@
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type TokenType int
 
@@ -14,11 +17,11 @@ const (
 	RIGHT_BRACE
 	COMMA
 	DOT
-	MINUS
-	PLUS
 	SEMICOLON
-	SLASH
-	STAR
+	COLON
+	AMPERSAND
+	PIPE
+	CARET
 
 	// One or two character tokens.
 	BANG
@@ -27,17 +30,40 @@ const (
 	EQUAL_EQUAL
 	GREATER
 	GREATER_EQUAL
+	GREATER_GREATER
 	LESS
 	LESS_EQUAL
+	LESS_LESS
+	MINUS
+	MINUS_EQUAL
+	MINUS_MINUS
+	PERCENT
+	PERCENT_EQUAL
+	PLUS
+	PLUS_EQUAL
+	PLUS_PLUS
+	QUESTION
+	QUESTION_QUESTION
+	SLASH
+	SLASH_EQUAL
+	STAR
+	STAR_EQUAL
+	STAR_STAR
+	TILDE
+	// TILDE_SLASH is integer division; "//" starts a comment.
+	TILDE_SLASH
 
 	// Literals.
 	IDENTIFIER
 	STRING
+	// INTERPOLATION is the part of a string before a "${".
+	INTERPOLATION
 	NUMBER
 
 	// Keywords.
 	AND
 	CLASS
+	CONST
 	ELSE
 	FALSE
 	FUN
@@ -53,6 +79,9 @@ const (
 	VAR
 	WHILE
 
+	// Comments are only scanned on request, e.g. by the formatter.
+	COMMENT
+
 	EOF
 )
 
@@ -61,6 +90,51 @@ type Token struct {
 	lexeme  string
 	literal any
 	line    int
+	column  int
+	// trivia is only set by scanners that keep trivia.
+	trivia *TokenTrivia
+	// doc is the text of the /// comments right before the token.
+	doc string
+}
+
+type TriviaKind int
+
+const (
+	WHITESPACE_TRIVIA TriviaKind = iota
+	NEWLINE_TRIVIA
+	COMMENT_TRIVIA
+	// Text the scanner reported an error for, e.g. an unexpected character.
+	SKIPPED_TRIVIA
+)
+
+// Trivia is source text between tokens.
+type Trivia struct {
+	kind TriviaKind
+	text string
+}
+
+// TokenTrivia is the text around a token. Trailing trivia runs up to and
+// including the end of the token's line, everything else before a token
+// is leading trivia.
+type TokenTrivia struct {
+	leading  []Trivia
+	trailing []Trivia
+}
+
+// FullText returns the token with its trivia as it appeared in the source.
+func (t Token) FullText() string {
+	if t.trivia == nil {
+		return t.lexeme
+	}
+	var b strings.Builder
+	for _, trivia := range t.trivia.leading {
+		b.WriteString(trivia.text)
+	}
+	b.WriteString(t.lexeme)
+	for _, trivia := range t.trivia.trailing {
+		b.WriteString(trivia.text)
+	}
+	return b.String()
 }
 
 func (t Token) String() string {
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DumpTokens prints one token per line as "line:column TYPE lexeme
+// literal". Lexemes and string literals are quoted, so whitespace in
+// them stays visible.
+func DumpTokens(tokens []Token) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		fmt.Fprintf(&b, "%v:%v %v %q %v\n", t.line, t.column, t.tType, t.lexeme, literalString(t.literal))
+	}
+	return b.String()
+}
+
+func literalString(literal any) string {
+	switch literal := literal.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(literal)
+	case int64, *big.Int, *Decimal, float64:
+		return numberLiteral(literal)
+	}
+	return fmt.Sprint(literal)
+}
+
+// DumpTokensJSON prints the tokens as an indented JSON array.
+func DumpTokensJSON(tokens []Token) string {
+	list := []any{}
+	for _, t := range tokens {
+		list = append(list, map[string]any{
+			"type":    t.tType.String(),
+			"lexeme":  t.lexeme,
+			"literal": literalJSON(t.literal),
+			"line":    t.line,
+			"column":  t.column,
+		})
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(data) + "\n"
+}
+
+// literalJSON keeps the kind of a number literal visible in JSON: floats
+// keep their decimal point, and big integers and decimals are strings in
+// their source form, e.g. "1.10d".
+func literalJSON(literal any) any {
+	switch literal := literal.(type) {
+	case float64:
+		return json.Number(floatLiteral(literal))
+	case *big.Int, *Decimal:
+		return numberLiteral(literal)
+	}
+	return literal
+}
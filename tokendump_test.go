@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDumpTokens(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			"var a = 1.5;",
+			"1:1 VAR \"var\" nil\n" +
+				"1:5 IDENTIFIER \"a\" nil\n" +
+				"1:7 EQUAL \"=\" nil\n" +
+				"1:9 NUMBER \"1.5\" 1.5\n" +
+				"1:12 SEMICOLON \";\" nil\n" +
+				"1:13 EOF \"\" nil\n",
+		},
+		{
+			// A dot needs digits on both sides to be part of a number.
+			".5 1.",
+			"1:1 DOT \".\" nil\n" +
+				"1:2 NUMBER \"5\" 5\n" +
+				"1:4 NUMBER \"1\" 1\n" +
+				"1:5 DOT \".\" nil\n" +
+				"1:6 EOF \"\" nil\n",
+		},
+		{
+			"print \"a b\";\n  x >= 2",
+			"1:1 PRINT \"print\" nil\n" +
+				"1:7 STRING \"\\\"a b\\\"\" \"a b\"\n" +
+				"1:12 SEMICOLON \";\" nil\n" +
+				"2:3 IDENTIFIER \"x\" nil\n" +
+				"2:5 GREATER_EQUAL \">=\" nil\n" +
+				"2:8 NUMBER \"2\" 2\n" +
+				"2:9 EOF \"\" nil\n",
+		},
+	}
+	for _, test := range tests {
+		if got := DumpTokens(NewScanner(test.source).scanTokens()); got != test.want {
+			t.Errorf("DumpTokens(%q) =\n%v\nwant\n%v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestDumpTokensJSON(t *testing.T) {
+	var got []map[string]any
+	if err := json.Unmarshal([]byte(DumpTokensJSON(NewScanner(`x = "hi";`).scanTokens())), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"type": "IDENTIFIER", "lexeme": "x", "literal": nil, "line": 1.0, "column": 1.0},
+		{"type": "EQUAL", "lexeme": "=", "literal": nil, "line": 1.0, "column": 3.0},
+		{"type": "STRING", "lexeme": `"hi"`, "literal": "hi", "line": 1.0, "column": 5.0},
+		{"type": "SEMICOLON", "lexeme": ";", "literal": nil, "line": 1.0, "column": 9.0},
+		{"type": "EOF", "lexeme": "", "literal": nil, "line": 1.0, "column": 10.0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v tokens, want %v", len(got), len(want))
+	}
+	for n := range want {
+		for key, value := range want[n] {
+			if got[n][key] != value {
+				t.Errorf("token %v: %v = %v, want %v", n, key, got[n][key], value)
+			}
+		}
+	}
+}
+
+func TestDumpTokensJSONNumbers(t *testing.T) {
+	got := DumpTokensJSON(NewScanner("1 1.0 1.5d 1n").scanTokens())
+	for _, want := range []string{
+		`"literal": 1,`,
+		`"literal": 1.0,`,
+		`"literal": "1.5d",`,
+		`"literal": "1n",`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("DumpTokensJSON() doesn't contain %q:\n%v", want, got)
+		}
+	}
+}
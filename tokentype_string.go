@@ -14,44 +14,66 @@ func _() {
 	_ = x[RIGHT_BRACE-3]
 	_ = x[COMMA-4]
 	_ = x[DOT-5]
-	_ = x[MINUS-6]
-	_ = x[PLUS-7]
-	_ = x[SEMICOLON-8]
-	_ = x[SLASH-9]
-	_ = x[STAR-10]
+	_ = x[SEMICOLON-6]
+	_ = x[COLON-7]
+	_ = x[AMPERSAND-8]
+	_ = x[PIPE-9]
+	_ = x[CARET-10]
 	_ = x[BANG-11]
 	_ = x[BANG_EQUAL-12]
 	_ = x[EQUAL-13]
 	_ = x[EQUAL_EQUAL-14]
 	_ = x[GREATER-15]
 	_ = x[GREATER_EQUAL-16]
-	_ = x[LESS-17]
-	_ = x[LESS_EQUAL-18]
-	_ = x[IDENTIFIER-19]
-	_ = x[STRING-20]
-	_ = x[NUMBER-21]
-	_ = x[AND-22]
-	_ = x[CLASS-23]
-	_ = x[ELSE-24]
-	_ = x[FALSE-25]
-	_ = x[FUN-26]
-	_ = x[FOR-27]
-	_ = x[IF-28]
-	_ = x[NIL-29]
-	_ = x[OR-30]
-	_ = x[PRINT-31]
-	_ = x[RETURN-32]
-	_ = x[SUPER-33]
-	_ = x[THIS-34]
-	_ = x[TRUE-35]
-	_ = x[VAR-36]
-	_ = x[WHILE-37]
-	_ = x[EOF-38]
+	_ = x[GREATER_GREATER-17]
+	_ = x[LESS-18]
+	_ = x[LESS_EQUAL-19]
+	_ = x[LESS_LESS-20]
+	_ = x[MINUS-21]
+	_ = x[MINUS_EQUAL-22]
+	_ = x[MINUS_MINUS-23]
+	_ = x[PERCENT-24]
+	_ = x[PERCENT_EQUAL-25]
+	_ = x[PLUS-26]
+	_ = x[PLUS_EQUAL-27]
+	_ = x[PLUS_PLUS-28]
+	_ = x[QUESTION-29]
+	_ = x[QUESTION_QUESTION-30]
+	_ = x[SLASH-31]
+	_ = x[SLASH_EQUAL-32]
+	_ = x[STAR-33]
+	_ = x[STAR_EQUAL-34]
+	_ = x[STAR_STAR-35]
+	_ = x[TILDE-36]
+	_ = x[TILDE_SLASH-37]
+	_ = x[IDENTIFIER-38]
+	_ = x[STRING-39]
+	_ = x[INTERPOLATION-40]
+	_ = x[NUMBER-41]
+	_ = x[AND-42]
+	_ = x[CLASS-43]
+	_ = x[CONST-44]
+	_ = x[ELSE-45]
+	_ = x[FALSE-46]
+	_ = x[FUN-47]
+	_ = x[FOR-48]
+	_ = x[IF-49]
+	_ = x[NIL-50]
+	_ = x[OR-51]
+	_ = x[PRINT-52]
+	_ = x[RETURN-53]
+	_ = x[SUPER-54]
+	_ = x[THIS-55]
+	_ = x[TRUE-56]
+	_ = x[VAR-57]
+	_ = x[WHILE-58]
+	_ = x[COMMENT-59]
+	_ = x[EOF-60]
 }
 
-const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERANDCLASSELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEEOF"
+const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTSEMICOLONCOLONAMPERSANDPIPECARETBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALGREATER_GREATERLESSLESS_EQUALLESS_LESSMINUSMINUS_EQUALMINUS_MINUSPERCENTPERCENT_EQUALPLUSPLUS_EQUALPLUS_PLUSQUESTIONQUESTION_QUESTIONSLASHSLASH_EQUALSTARSTAR_EQUALSTAR_STARTILDETILDE_SLASHIDENTIFIERSTRINGINTERPOLATIONNUMBERANDCLASSCONSTELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILECOMMENTEOF"
 
-var _TokenType_index = [...]uint8{0, 10, 21, 31, 42, 47, 50, 55, 59, 68, 73, 77, 81, 91, 96, 107, 114, 127, 131, 141, 151, 157, 163, 166, 171, 175, 180, 183, 186, 188, 191, 193, 198, 204, 209, 213, 217, 220, 225, 228}
+var _TokenType_index = [...]uint16{0, 10, 21, 31, 42, 47, 50, 59, 64, 73, 77, 82, 86, 96, 101, 112, 119, 132, 147, 151, 161, 170, 175, 186, 197, 204, 217, 221, 231, 240, 248, 265, 270, 281, 285, 295, 304, 309, 320, 330, 336, 349, 355, 358, 363, 368, 372, 377, 380, 383, 385, 388, 390, 395, 401, 406, 410, 414, 417, 422, 429, 432}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Tracer logs what a program does: every statement, every call with its
+// arguments and return value, or the error unwinding it, and every
+// variable access with the (distance, name) pair the interpreter resolved
+// it to. Lines are indented by call depth.
+type Tracer struct {
+	interpreter *Interpreter
+	out         io.Writer
+	// functions, if not empty, limits the trace to calls of these
+	// functions and everything they call.
+	functions map[string]bool
+	calls     []string
+	tracing   int
+}
+
+var accessNames = map[VariableAccess]string{
+	VARIABLE_READ:   "read",
+	VARIABLE_ASSIGN: "assign",
+	VARIABLE_DEFINE: "define",
+}
+
+// NewTracer starts tracing i to out. Without function names the whole
+// program is traced.
+func NewTracer(i *Interpreter, out io.Writer, functions []string) *Tracer {
+	t := &Tracer{interpreter: i, out: out, functions: map[string]bool{}}
+	for _, name := range functions {
+		t.functions[name] = true
+	}
+
+	onStmt, onCall, onReturn, onVariable := i.onStmt, i.onCall, i.onReturn, i.onVariable
+	i.onStmt = func(stmt Stmt) {
+		if line := stmtLine(stmt); line > 0 {
+			t.log("[line %v] %v", line, reflect.TypeOf(stmt).Elem().Name())
+		}
+		if onStmt != nil {
+			onStmt(stmt)
+		}
+	}
+	i.onCall = func(fn LoxFunction, args []any) {
+		name := fn.declaration.name.lexeme
+		if t.functions[name] {
+			t.tracing++
+		}
+		var params []string
+		for n, param := range fn.declaration.params {
+			params = append(params, param.lexeme+" = "+t.interpreter.stringify(args[n]))
+		}
+		t.log("call %v(%v)", name, strings.Join(params, ", "))
+		t.calls = append(t.calls, name)
+		if onCall != nil {
+			onCall(fn, args)
+		}
+	}
+	i.onReturn = func(fn LoxFunction, value any, unwinding bool) {
+		name := fn.declaration.name.lexeme
+		t.calls = t.calls[:len(t.calls)-1]
+		if unwinding {
+			t.log("unwind %v", name)
+		} else {
+			t.log("return %v = %v", name, t.interpreter.stringify(value))
+		}
+		if t.functions[name] {
+			t.tracing--
+		}
+		if onReturn != nil {
+			onReturn(fn, value, unwinding)
+		}
+	}
+	i.onVariable = func(access VariableAccess, name Token, distance int, value any) {
+		where := "global"
+		if distance >= 0 {
+			where = fmt.Sprint(distance)
+		}
+		t.log("%v (%v, %v) = %v", accessNames[access], where, name.lexeme, t.interpreter.stringify(value))
+		if onVariable != nil {
+			onVariable(access, name, distance, value)
+		}
+	}
+	return t
+}
+
+func (t *Tracer) log(format string, args ...any) {
+	if len(t.functions) > 0 && t.tracing == 0 {
+		return
+	}
+	fmt.Fprintf(t.out, strings.Repeat("  ", len(t.calls))+format+"\n", args...)
+}
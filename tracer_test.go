@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	source := `var total = 0;
+fun add(n) {
+  total = total + n;
+  return total;
+}
+{
+  var x = add(2);
+}
+add(3);
+`
+	tests := []struct {
+		functions []string
+		want      string
+	}{
+		{nil, `[line 1] Var
+define (global, total) = 0
+[line 2] Function
+define (global, add) = <fn add>
+[line 7] Var
+read (global, add) = <fn add>
+call add(n = 2)
+  [line 3] Expression
+  read (global, total) = 0
+  read (0, n) = 2
+  assign (global, total) = 2
+  [line 4] Return
+  read (global, total) = 2
+return add = 2
+define (0, x) = 2
+[line 9] Expression
+read (global, add) = <fn add>
+call add(n = 3)
+  [line 3] Expression
+  read (global, total) = 2
+  read (0, n) = 3
+  assign (global, total) = 5
+  [line 4] Return
+  read (global, total) = 5
+return add = 5
+`},
+		{[]string{"add"}, `call add(n = 2)
+  [line 3] Expression
+  read (global, total) = 0
+  read (0, n) = 2
+  assign (global, total) = 2
+  [line 4] Return
+  read (global, total) = 2
+return add = 2
+call add(n = 3)
+  [line 3] Expression
+  read (global, total) = 2
+  read (0, n) = 3
+  assign (global, total) = 5
+  [line 4] Return
+  read (global, total) = 5
+return add = 5
+`},
+		{[]string{"sub"}, ""},
+	}
+	for _, test := range tests {
+		i := NewInterpreter()
+		i.stdout = io.Discard
+		var out bytes.Buffer
+		NewTracer(i, &out, test.functions)
+		stmts := NewParser(NewScanner(source).scanTokens()).parse()
+		resolver := NewResolver(*i)
+		resolver.resolveStmts(stmts)
+		i.Interpret(stmts)
+		if out.String() != test.want {
+			t.Errorf("trace of %v =\n%v\nwant\n%v", test.functions, out.String(), test.want)
+		}
+	}
+}
+
+func TestTracerAfterRuntimeError(t *testing.T) {
+	tests := []struct {
+		functions []string
+		want      string
+	}{
+		{nil, `[line 1] Function
+define (global, fail) = <fn fail>
+[line 1] Expression
+read (global, fail) = <fn fail>
+call fail()
+  [line 1] Return
+unwind fail
+[line 1] Print
+`},
+		{[]string{"fail"}, `call fail()
+  [line 1] Return
+unwind fail
+`},
+	}
+	for _, test := range tests {
+		i := NewInterpreter()
+		i.stdout = io.Discard
+		var out bytes.Buffer
+		NewTracer(i, &out, test.functions)
+		// Like in the REPL, the interpreter is used again after the error.
+		for _, source := range []string{"fun fail() { return 1 + nil; } fail();", "print 1;"} {
+			stmts := NewParser(NewScanner(source).scanTokens()).parse()
+			resolver := NewResolver(*i)
+			resolver.resolveStmts(stmts)
+			i.Interpret(stmts)
+		}
+		if out.String() != test.want {
+			t.Errorf("trace of %v =\n%v\nwant\n%v", test.functions, out.String(), test.want)
+		}
+	}
+}
+
+func TestTracerForLoop(t *testing.T) {
+	i := NewInterpreter()
+	i.stdout = io.Discard
+	var out bytes.Buffer
+	NewTracer(i, &out, nil)
+	stmts := NewParser(NewScanner("for (var i = 0; i < 2; i = i + 1) print i;").scanTokens()).parse()
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(stmts)
+	i.Interpret(stmts)
+	// The initializer and the increment aren't statements of their own.
+	want := `[line 1] For
+define (0, i) = 0
+read (0, i) = 0
+[line 1] Print
+read (1, i) = 0
+read (1, i) = 0
+assign (1, i) = 1
+read (0, i) = 1
+[line 1] Print
+read (1, i) = 1
+read (1, i) = 1
+assign (1, i) = 2
+read (0, i) = 2
+`
+	if out.String() != want {
+		t.Errorf("trace =\n%v\nwant\n%v", out.String(), want)
+	}
+}
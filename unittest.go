@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TestResult is the outcome of one test_* function. failure is empty if
+// the test passed.
+type TestResult struct {
+	name    string
+	failure string
+}
+
+// testFunctions returns the top-level test_* functions in the order they
+// are declared.
+func testFunctions(stmts []Stmt) []*Function {
+	var tests []*Function
+	for _, stmt := range stmts {
+		if fn, ok := stmt.(*Function); ok && strings.HasPrefix(fn.name.lexeme, "test_") {
+			tests = append(tests, fn)
+		}
+	}
+	return tests
+}
+
+func isTestFile(source string) bool {
+	var stmts []Stmt
+	captureErrors(func() {
+		stmts = NewParser(NewScanner(source).scanTokens()).parse()
+	})
+	return len(testFunctions(stmts)) > 0
+}
+
+// RunTests runs the top-level code of source and then calls each of its
+// test_* functions. Every test runs in a new interpreter that ran the
+// top-level code again, so tests can't affect each other, not even
+// through variables captured by closures. The program's output is written
+// to out, but that of the top-level code only once. Errors in the
+// top-level code are returned instead of running the tests.
+func RunTests(source string, out io.Writer) (results []TestResult, err error) {
+	stderr := captureErrors(func() {
+		stmts := NewParser(NewScanner(source).scanTokens()).parse()
+		if hadError {
+			return
+		}
+		i := runTopLevel(stmts, out)
+		for n, fn := range testFunctions(stmts) {
+			if n > 0 {
+				i = runTopLevel(stmts, io.Discard)
+			}
+			if i == nil {
+				return
+			}
+			i.stdout = out
+			results = append(results, runTest(i, fn.name))
+		}
+	})
+	if stderr != "" {
+		return results, fmt.Errorf("%v", strings.TrimSpace(stderr))
+	}
+	return results, nil
+}
+
+// runTopLevel resolves and runs stmts in a new interpreter that writes to
+// out. It returns nil if that reported errors.
+func runTopLevel(stmts []Stmt, out io.Writer) *Interpreter {
+	i := NewInterpreter()
+	i.stdout = out
+	resolver := NewResolver(*i)
+	resolver.resolveStmts(stmts)
+	if hadError {
+		return nil
+	}
+	i.Interpret(stmts)
+	if hadRuntimeError {
+		return nil
+	}
+	return i
+}
+
+func runTest(i *Interpreter, name Token) (result TestResult) {
+	result.name = name.lexeme
+	defer func() {
+		if err := recover(); err != nil {
+			if err, ok := err.(RuntimeError); ok {
+				result.failure = fmt.Sprintf("%v [line %v]", err.msg, err.token.line)
+				return
+			}
+			result.failure = fmt.Sprint(err)
+		}
+	}()
+
+	fn, ok := i.globals.values[name.lexeme].(LoxCallable)
+	if !ok {
+		result.failure = "Not a function."
+	} else if fn.Arity() != 0 {
+		result.failure = "Test functions can't have parameters."
+	} else {
+		fn.Call(i, name, nil)
+	}
+	return result
+}
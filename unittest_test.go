@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRunTests(t *testing.T) {
+	source := `var list = "";
+print "loaded";
+fun helper() {}
+fun test_pass() {
+  list = list + "a";
+  assertEqual(list, "a");
+}
+fun test_fail() {
+  assertEqual(list, "a");
+}
+fun test_assert() {
+  assert(nil);
+}
+fun test_args(x) {}
+fun test_error() {
+  print "in test";
+  return -"a";
+}
+`
+	var out bytes.Buffer
+	results, err := RunTests(source, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TestResult{
+		{"test_pass", ""},
+		{"test_fail", "Expected a but got . [line 9]"},
+		{"test_assert", "Assertion failed: <nil> is not true. [line 12]"},
+		{"test_args", "Test functions can't have parameters."},
+		{"test_error", "Operand must be a number. [line 17]"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("RunTests() = %q, want %q", results, want)
+	}
+	if out.String() != "loaded\nin test\n" {
+		t.Errorf("got output %q", out.String())
+	}
+
+	if _, err := RunTests("fun test_x() {}\nprint x;\n", &out); err == nil || err.Error() != "Undefined variable 'x'. \n[line 2]" {
+		t.Errorf("RunTests() error = %q", err)
+	}
+	closures := `fun makeCounter() {
+  var n = 0;
+  fun next() {
+    n = n + 1;
+    return n;
+  }
+  return next;
+}
+var next = makeCounter();
+print "loaded";
+fun test_first() {
+  assertEqual(next(), 1);
+}
+fun test_second() {
+  assertEqual(next(), 1);
+}
+`
+	out.Reset()
+	results, err = RunTests(closures, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []TestResult{{"test_first", ""}, {"test_second", ""}}; !reflect.DeepEqual(results, want) {
+		t.Errorf("closure state leaked between tests: RunTests() = %q", results)
+	}
+	if out.String() != "loaded\n" {
+		t.Errorf("got output %q, want the top-level output once", out.String())
+	}
+
+	if isTestFile("fun testing() {}") || !isTestFile("fun test_x() {}") {
+		t.Error("isTestFile() doesn't look for test_* functions")
+	}
+}